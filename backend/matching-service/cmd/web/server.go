@@ -1,17 +1,32 @@
 package main
 
 import (
-	"log"
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"matching-service/internal/auth"
 	"matching-service/internal/config"
+	"matching-service/internal/cors"
 	"matching-service/internal/handlers"
+	"matching-service/internal/health"
+	"matching-service/internal/logging"
+	"matching-service/internal/metrics"
+	"matching-service/internal/ratelimit"
 	"matching-service/internal/repository"
 	"matching-service/internal/services"
+	"matching-service/internal/tracing"
+	"matching-service/internal/validation"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
-	"github.com/joho/godotenv"
+	"github.com/go-redis/redis/v8"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 func root(c *gin.Context) {
@@ -20,69 +35,298 @@ func root(c *gin.Context) {
 	})
 }
 
-func healthCheck(c *gin.Context) {
-	c.JSON(200, gin.H{
-		"message": "Matching service is running",
-	})
+// healthSources are the components whose state the health endpoint reports
+type healthSources struct {
+	questionCache *repository.CachedQuestionRepository
+	breakers      []*repository.CircuitBreaker
+	// readiness lists the dependencies /health/ready probes
+	readiness        []health.Check
+	readinessTimeout time.Duration
 }
 
-func corsMiddleware() gin.HandlerFunc {
+func healthCheck(sources healthSources) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "https://cs3219-ay2526s1-project-g11.vercel.app")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
-		c.Header("Access-Control-Allow-Credentials", "true")
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
+		body := gin.H{"message": "Matching service is running"}
+		if sources.questionCache != nil {
+			body["questionCache"] = sources.questionCache.Stats()
+		}
+		if len(sources.breakers) > 0 {
+			dependencies := make([]repository.BreakerStatus, 0, len(sources.breakers))
+			for _, breaker := range sources.breakers {
+				dependencies = append(dependencies, breaker.Status())
+			}
+			body["dependencies"] = dependencies
 		}
-		c.Next()
+		c.JSON(200, body)
 	}
 }
 
-// setupRouter builds and returns the Gin engine with all routes.
-func setupRouter() *gin.Engine {
-	r := gin.Default()
-
-	// Configure CORS
-	r.Use(cors.New(cors.Config{
-		AllowOrigins: []string{"https://cs3219-ay2526s1-project-g11.vercel.app"},
-		// For production, replace with specific origins:
-		// AllowOrigins:     []string{"http://localhost:3000", "https://your-frontend-domain.com"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * 3600, // 12 hours
-	}))
+// liveness only reports that the process is serving requests, so an orchestrator restarts
+// the replica if it stops answering
+func liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "alive"})
+}
+
+// readiness probes the dependencies and answers 503 while a required one is unavailable,
+// so an orchestrator stops routing traffic to the replica
+func readiness(sources healthSources) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := health.Run(c.Request.Context(), sources.readiness, sources.readinessTimeout)
+		status := http.StatusOK
+		if !report.Ready() {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, report)
+	}
+}
+
+// setupRouter builds and returns the Gin engine with all routes. Client IPs are only taken
+// from X-Forwarded-For on connections from trustedProxies; with none, anyone could pick their
+// own IP and dodge the per-IP rate limits.
+func setupRouter(health healthSources, corsPolicy *cors.Policy, m *metrics.Metrics, trustedProxies []string) (*gin.Engine, error) {
+	r := gin.New()
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		return nil, err
+	}
+	// The WebSocket token is hidden first, before anything can log or trace the URL
+	r.Use(auth.HideQueryToken(), gin.Recovery(), otelgin.Middleware("matching-service"), logging.Middleware(), m.Middleware(), cors.Middleware(corsPolicy))
 
 	r.GET("/", root)
-	r.GET("/health", healthCheck)
-	return r
+	r.GET("/health", healthCheck(health))
+	r.GET("/health/live", liveness)
+	r.GET("/health/ready", readiness(health))
+	if m != nil {
+		r.GET("/metrics", gin.WrapH(m.Handler()))
+	}
+	return r, nil
+}
+
+// readinessChecks lists the dependencies a replica needs. Only Redis is required: without
+// user-service or question-service matches degrade but the queues keep working.
+func readinessChecks(cfg config.Config, redisClient *redis.Client, userRepo *repository.UserRepository, questionRepo *repository.QuestionRepository) []health.Check {
+	checks := []health.Check{{
+		Name:     "redis",
+		Required: true,
+		Probe:    func(ctx context.Context) error { return redisClient.Ping(ctx).Err() },
+	}}
+	if cfg.ReadinessProbeServices {
+		checks = append(checks,
+			health.Check{Name: "user-service", Probe: userRepo.Ping},
+			health.Check{Name: "question-service", Probe: questionRepo.Ping},
+		)
+	}
+	return checks
+}
+
+// fatal logs err and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
 
 func main() {
-	cfg := config.Load()
+	cfg, err := config.Load(config.LoadOptions{DotEnv: ".env"})
+	if err != nil {
+		fatal("failed to load configuration", err)
+	}
+	level, _ := logging.ParseLevel(cfg.LogLevel) // checked by config.Load
+	slog.SetDefault(logging.New(os.Stdout, level))
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    cfg.TracingExporter,
+		ServiceName: cfg.TracingServiceName,
+	})
+	if err != nil {
+		fatal("failed to set up tracing", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("failed to flush traces", "error", err)
+		}
+	}()
+
 	redisClient := repository.NewRedisClient(cfg.RedisURL)
+	redisClient.AddHook(tracing.RedisHook{})
 	repo := repository.NewMatchRepository(redisClient)
-	userRepo := repository.NewUserRepository(cfg.UserServiceURL)
-	questionRepo := repository.NewQuestionRepository(cfg.QuestionServiceURL)
-	service := services.NewMatchingService(repo, userRepo, questionRepo)
+	if err := repo.IndexQueues(context.Background()); err != nil {
+		slog.Warn("failed to index existing queues", "error", err)
+	}
+	appMetrics := metrics.New()
+	appMetrics.WatchQueues(repo.GetAllQueues)
+	outbound := func(name string) *repository.OutboundClient {
+		return repository.NewOutboundClient(repository.OutboundOptions{
+			Name:             name,
+			Timeout:          cfg.OutboundTimeout,
+			MaxRetries:       cfg.OutboundRetries,
+			FailureThreshold: cfg.BreakerFailureThreshold,
+			OpenFor:          cfg.BreakerOpenFor,
+			Metrics:          appMetrics,
+		})
+	}
+	userRepo := repository.NewUserRepository(cfg.UserServiceURL, outbound("user-service"))
+	questionRepo := repository.NewQuestionRepository(cfg.QuestionServiceURL, outbound("question-service"))
+	var questionLookup repository.QuestionLookup = questionRepo
+	var questionCache *repository.CachedQuestionRepository
+	if cfg.QuestionCacheTTL > 0 {
+		questionCache = repository.NewCachedQuestionRepository(redisClient, questionRepo, repository.QuestionCacheOptions{
+			TTL:     cfg.QuestionCacheTTL,
+			Metrics: appMetrics,
+		})
+		questionLookup = questionCache
+	} else {
+		slog.Info("QUESTION_CACHE_TTL_SECONDS is 0; every match calls question-service")
+	}
+	historyRepo := repository.NewRedisMatchHistoryRepository(redisClient, cfg.MatchHistoryPerUser)
+	auditLog := repository.NewRedisAuditLogRepository(redisClient)
+	service := services.NewMatchingService(repo, userRepo, questionLookup, historyRepo, services.Options{
+		Relaxation: services.RelaxationPolicy{
+			TopicsAfter:     cfg.RelaxTopicsAfter,
+			DifficultyAfter: cfg.RelaxDifficultyAfter,
+		},
+		MaxQueueWait:   cfg.MaxQueueWait,
+		HeartbeatGrace: cfg.HeartbeatGrace,
+		AcceptTimeout:  cfg.AcceptTimeout,
+		MatchBudget:    cfg.MatchBudget,
+
+		RequeuePartnerOnCancel: cfg.RequeuePartnerOnCancel,
+		Audit:                  auditLog,
+		Metrics:                appMetrics,
+	})
 
-	_ = godotenv.Load(".env") // non-fatal if missing
-	appEnv := os.Getenv("APP_ENV")
-	if appEnv == "production" {
+	// Background workers stop when workerCtx is cancelled during shutdown
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var workers sync.WaitGroup
+	startWorker := func(run func(context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(workerCtx)
+		}()
+	}
+	startWorker(services.NewMatchmakerWorker(service, cfg.MatchmakerInterval).Run)
+	catalogue := validation.NewCatalogue(cfg.CatalogueTopics)
+	startWorker(func(ctx context.Context) { catalogue.RunRefresh(ctx, questionRepo, cfg.CatalogueRefreshInterval) })
+	if questionCache != nil {
+		startWorker(func(ctx context.Context) { questionCache.RunRefresh(ctx, cfg.QuestionCacheRefreshInterval) })
+	}
+
+	if cfg.AppEnv == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
-	log.Println("APP_ENV: " + appEnv)
-	router := setupRouter()
-	handlers.RegisterRoutes(router, service)
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	slog.Info("starting", "app_env", cfg.AppEnv, "allowed_origins", cfg.AllowedOrigins)
+	// Validate has refused to start without a verifier unless AUTH_DISABLED is set
+	var verifier *auth.Verifier
+	if cfg.AuthDisabled {
+		slog.Warn("AUTH_DISABLED is set; match API is unauthenticated and admin routes are unavailable")
+	} else {
+		verifier, err = auth.NewVerifier(auth.Options{
+			Secret:       cfg.JWTSecret,
+			JWKSFile:     cfg.JWKSFile,
+			AdminUserIDs: cfg.AdminUserIDs,
+		})
+		if err != nil {
+			fatal("failed to set up authentication", err)
+		}
 	}
-	log.Printf("listening on :%s", port)
-	if err := router.Run("0.0.0.0:" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	corsPolicy, err := cors.NewPolicy(cfg.AllowedOrigins)
+	if err != nil {
+		fatal("invalid CORS origins", err)
+	}
+	router, err := setupRouter(healthSources{
+		questionCache: questionCache,
+		breakers:      []*repository.CircuitBreaker{userRepo.Breaker(), questionRepo.Breaker()},
+
+		readiness:        readinessChecks(cfg, redisClient, userRepo, questionRepo),
+		readinessTimeout: cfg.ReadinessTimeout,
+	}, corsPolicy, appMetrics, cfg.TrustedProxies)
+	if err != nil {
+		fatal("invalid trusted proxies", err)
+	}
+	limiter := ratelimit.New(repository.NewRateLimitRepository(redisClient), ratelimit.Options{
+		Rules: map[string]ratelimit.Rule{
+			ratelimit.RouteMatch:        {PerUser: cfg.RateLimitMatchPerUser, PerIP: cfg.RateLimitMatchPerIP},
+			ratelimit.RouteMatchRequest: {PerUser: cfg.RateLimitMatchRequestPerUser, PerIP: cfg.RateLimitMatchRequestPerIP},
+		},
+		Metrics: appMetrics,
+	})
+	handlers.RegisterRoutes(router, service, verifier, catalogue, limiter, corsPolicy)
+	handlers.RegisterAdminRoutes(router, service, verifier)
+	// Requests and WebSockets get their contexts from connCtx, so WebSockets, which
+	// http.Server.Shutdown does not wait for, can be closed once the drain is done
+	connCtx, closeConns := context.WithCancel(context.Background())
+	defer closeConns()
+	srv := &http.Server{
+		Addr:        "0.0.0.0:" + cfg.Port,
+		Handler:     router,
+		BaseContext: func(net.Listener) context.Context { return connCtx },
+	}
+
+	signalCtx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("listening", "port", cfg.Port)
+		serveErr <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-serveErr:
+		fatal("failed to start server", err)
+	case <-signalCtx.Done():
+	}
+	// A second signal kills the process without waiting for the drain
+	stopSignals()
+
+	slog.Info("shutting down", "drain_timeout", cfg.ShutdownDrainTimeout.String())
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), cfg.ShutdownDrainTimeout)
+	defer cancelDrain()
+	shutdown(drainCtx, shutdownSteps{
+		service:     service,
+		server:      srv,
+		closeConns:  closeConns,
+		stopWorkers: stopWorkers,
+		workers:     &workers,
+		redis:       redisClient,
+	})
+}
+
+// shutdownSteps are the parts of the service stopped, in order, on shutdown
+type shutdownSteps struct {
+	service     *services.MatchingService
+	server      *http.Server
+	closeConns  context.CancelFunc
+	stopWorkers context.CancelFunc
+	workers     *sync.WaitGroup
+	redis       *redis.Client
+}
+
+// shutdown stops accepting match requests, lets in-flight requests finish pairing, closes
+// WebSockets, stops the background workers and closes Redis. Steps still running when ctx
+// expires are abandoned; claims are atomic, so an interrupted pairing never loses users.
+func shutdown(ctx context.Context, steps shutdownSteps) {
+	steps.service.StopAccepting()
+
+	if err := steps.server.Shutdown(ctx); err != nil {
+		slog.Warn("in-flight requests did not finish before the drain timeout", "error", err)
+	}
+	// Clients reconnect to another replica when their WebSocket closes
+	steps.closeConns()
+
+	steps.stopWorkers()
+	done := make(chan struct{})
+	go func() {
+		steps.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		slog.Warn("background workers did not stop before the drain timeout")
+	}
+
+	if err := steps.redis.Close(); err != nil {
+		slog.Error("failed to close Redis client", "error", err)
 	}
+	slog.Info("shutdown complete")
 }
@@ -1,13 +1,45 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"matching-service/internal/cors"
+	"matching-service/internal/health"
+	"matching-service/internal/logging"
+	"matching-service/internal/ratelimit"
+	"matching-service/internal/repository"
+	"matching-service/internal/services"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
 )
 
+// testCORS allows the local frontend
+var testCORS, _ = cors.NewPolicy([]string{"http://localhost:3000"})
+
+func newTestRouter(t *testing.T, health healthSources, trustedProxies []string) *gin.Engine {
+	t.Helper()
+	router, err := setupRouter(health, testCORS, nil, trustedProxies)
+	if err != nil {
+		t.Fatalf("setupRouter: %v", err)
+	}
+	return router
+}
+
 func TestHealthEndpoint(t *testing.T) {
-	router := setupRouter()
+	router := newTestRouter(t, healthSources{}, nil)
 	req := httptest.NewRequest(http.MethodGet, "/health", nil)
 	resp := httptest.NewRecorder()
 
@@ -17,3 +49,201 @@ func TestHealthEndpoint(t *testing.T) {
 		t.Fatalf("expected status 200, got %d", resp.Code)
 	}
 }
+
+func TestLivenessEndpoint(t *testing.T) {
+	router := newTestRouter(t, healthSources{}, nil)
+	req := httptest.NewRequest(http.MethodGet, "/health/live", nil)
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.Code)
+	}
+}
+
+func TestReadinessEndpoint(t *testing.T) {
+	up := func(context.Context) error { return nil }
+	down := func(context.Context) error { return errors.New("connection refused") }
+
+	tests := []struct {
+		name       string
+		checks     []health.Check
+		wantStatus int
+	}{
+		{name: "redis up", checks: []health.Check{{Name: "redis", Required: true, Probe: up}}, wantStatus: http.StatusOK},
+		{name: "redis down", checks: []health.Check{{Name: "redis", Required: true, Probe: down}}, wantStatus: http.StatusServiceUnavailable},
+		{name: "optional service down", checks: []health.Check{{Name: "redis", Required: true, Probe: up}, {Name: "user-service", Probe: down}}, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := newTestRouter(t, healthSources{readiness: tt.checks, readinessTimeout: time.Second}, nil)
+			req := httptest.NewRequest(http.MethodGet, "/health/ready", nil)
+			resp := httptest.NewRecorder()
+
+			router.ServeHTTP(resp, req)
+
+			if resp.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, resp.Code)
+			}
+			var report health.Report
+			if err := json.Unmarshal(resp.Body.Bytes(), &report); err != nil {
+				t.Fatalf("decoding report: %v", err)
+			}
+			if len(report.Dependencies) != len(tt.checks) {
+				t.Fatalf("report lists %d dependencies, want %d", len(report.Dependencies), len(tt.checks))
+			}
+		})
+	}
+}
+
+func TestRouterKeepsWebSocketTokensOutOfLogs(t *testing.T) {
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(logging.New(&logs, slog.LevelInfo))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	router := newTestRouter(t, healthSources{}, nil)
+	router.GET("/match/ws/:userId", func(c *gin.Context) {
+		if c.Request.URL.Query().Has("token") {
+			panic("token reached the handler")
+		}
+		c.String(http.StatusOK, c.GetHeader("Authorization"))
+	})
+	req := httptest.NewRequest(http.MethodGet, "/match/ws/u1?token=secret-token", nil)
+	req.Header.Set("Upgrade", "websocket")
+	resp := httptest.NewRecorder()
+
+	router.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusOK || resp.Body.String() != "Bearer secret-token" {
+		t.Fatalf("response = %d %q, want the token moved into the Authorization header", resp.Code, resp.Body.String())
+	}
+	if !strings.Contains(logs.String(), "/match/ws/u1") || strings.Contains(logs.String(), "secret-token") {
+		t.Fatalf("request log leaks the token: %s", logs.String())
+	}
+}
+
+func TestForwardedForOnlyTrustedFromConfiguredProxies(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	tests := []struct {
+		name           string
+		trustedProxies []string
+		remoteAddr     string
+		forwardedFor   func(i int) string
+	}{
+		{
+			name:         "no trusted proxies",
+			remoteAddr:   "203.0.113.7:1234",
+			forwardedFor: func(i int) string { return fmt.Sprintf("198.51.100.%d", i) },
+		},
+		{
+			// The proxy appends the address it saw to whatever the client sent
+			name:           "behind a trusted proxy",
+			trustedProxies: []string{"169.254.0.0/16"},
+			remoteAddr:     "169.254.1.1:1234",
+			forwardedFor:   func(i int) string { return fmt.Sprintf("198.51.100.%d, 203.0.113.7", i) },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr.FlushAll()
+			limiter := ratelimit.New(repository.NewRateLimitRepository(client), ratelimit.Options{
+				Rules: map[string]ratelimit.Rule{ratelimit.RouteMatchRequest: {PerIP: 1}},
+			})
+			router := newTestRouter(t, healthSources{}, tt.trustedProxies)
+			var clientIPs []string
+			router.POST("/match/request", limiter.Middleware(ratelimit.RouteMatchRequest), func(c *gin.Context) {
+				clientIPs = append(clientIPs, c.ClientIP())
+				c.Status(http.StatusOK)
+			})
+
+			for i := 1; i <= 2; i++ {
+				req := httptest.NewRequest(http.MethodPost, "/match/request", nil)
+				req.RemoteAddr = tt.remoteAddr
+				req.Header.Set("X-Forwarded-For", tt.forwardedFor(i))
+				resp := httptest.NewRecorder()
+				router.ServeHTTP(resp, req)
+
+				want := http.StatusOK
+				if i == 2 {
+					want = http.StatusTooManyRequests
+				}
+				if resp.Code != want {
+					t.Fatalf("request %d status = %d, want %d: a spoofed X-Forwarded-For must not reset the limit", i, resp.Code, want)
+				}
+			}
+			if len(clientIPs) != 1 || clientIPs[0] != "203.0.113.7" {
+				t.Fatalf("client IPs = %v, want the real client 203.0.113.7", clientIPs)
+			}
+		})
+	}
+}
+
+func TestShutdownDrainsInFlightRequests(t *testing.T) {
+	history := repository.NewMemoryMatchHistoryRepository()
+	service := services.NewMatchingService(repository.NewMemoryMatchStore(), repository.NewMemoryUserLookup(),
+		repository.NewMemoryQuestionLookup(), history, services.Options{})
+	mr := miniredis.RunT(t)
+	redisClient := repository.NewRedisClient(mr.Addr())
+
+	started := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	})
+	srv := httptest.NewUnstartedServer(mux)
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	workers.Add(1)
+	workerStopped := false
+	go func() {
+		defer workers.Done()
+		<-workerCtx.Done()
+		workerStopped = true
+	}()
+
+	status := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(srv.URL + "/slow")
+		if err != nil {
+			status <- 0
+			return
+		}
+		resp.Body.Close()
+		status <- resp.StatusCode
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdown(ctx, shutdownSteps{
+		service:     service,
+		server:      srv.Config,
+		closeConns:  func() {},
+		stopWorkers: stopWorkers,
+		workers:     &workers,
+		redis:       redisClient,
+	})
+
+	if code := <-status; code != http.StatusOK {
+		t.Fatalf("in-flight request finished with %d, want 200", code)
+	}
+	if !service.ShuttingDown() {
+		t.Fatal("service still accepts match requests")
+	}
+	if !workerStopped {
+		t.Fatal("background worker was not stopped")
+	}
+	if err := redisClient.Ping(context.Background()).Err(); err == nil {
+		t.Fatal("Redis client is still open")
+	}
+}
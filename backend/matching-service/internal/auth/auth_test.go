@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "test-secret"
+
+func signHMAC(t *testing.T, claims jwt.MapClaims, secret string) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+	return token
+}
+
+func validClaims(userID string) jwt.MapClaims {
+	return jwt.MapClaims{"id": userID, "exp": time.Now().Add(time.Hour).Unix()}
+}
+
+func TestVerifyHMAC(t *testing.T) {
+	verifier, err := NewVerifier(Options{Secret: testSecret, AdminUserIDs: []string{"boss"}})
+	if err != nil {
+		t.Fatalf("NewVerifier returned error: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		token     string
+		wantUser  string
+		wantAdmin bool
+		wantErr   bool
+	}{
+		{name: "user-service token", token: signHMAC(t, validClaims("u1"), testSecret), wantUser: "u1"},
+		{name: "subject claim", token: signHMAC(t, jwt.MapClaims{"sub": "u2", "exp": time.Now().Add(time.Hour).Unix()}, testSecret), wantUser: "u2"},
+		{name: "admin claim is ignored", token: signHMAC(t, jwt.MapClaims{"id": "u3", "isAdmin": true, "exp": time.Now().Add(time.Hour).Unix()}, testSecret), wantUser: "u3"},
+		{name: "configured admin", token: signHMAC(t, validClaims("boss"), testSecret), wantUser: "boss", wantAdmin: true},
+		{name: "wrong secret", token: signHMAC(t, validClaims("u1"), "other"), wantErr: true},
+		{name: "expired", token: signHMAC(t, jwt.MapClaims{"id": "u1", "exp": time.Now().Add(-time.Minute).Unix()}, testSecret), wantErr: true},
+		{name: "no expiry", token: signHMAC(t, jwt.MapClaims{"id": "u1"}, testSecret), wantErr: true},
+		{name: "no user", token: signHMAC(t, jwt.MapClaims{"exp": time.Now().Add(time.Hour).Unix()}, testSecret), wantErr: true},
+		{name: "garbage", token: "not.a.token", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := verifier.Verify(tt.token)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Verify = %+v, want an error", claims)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Verify returned error: %v", err)
+			}
+			if claims.UserID != tt.wantUser || claims.IsAdmin != tt.wantAdmin {
+				t.Fatalf("claims = %+v, want user %s admin %v", claims, tt.wantUser, tt.wantAdmin)
+			}
+		})
+	}
+}
+
+func TestVerifyJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generating key: %v", err)
+	}
+	jwks := map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": "k1",
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}}
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	data, _ := json.Marshal(jwks)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("writing JWKS: %v", err)
+	}
+
+	verifier, err := NewVerifier(Options{JWKSFile: path})
+	if err != nil {
+		t.Fatalf("NewVerifier returned error: %v", err)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, validClaims("u1"))
+	token.Header["kid"] = "k1"
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+	if claims, err := verifier.Verify(signed); err != nil || claims.UserID != "u1" {
+		t.Fatalf("Verify = %+v, %v; want u1", claims, err)
+	}
+
+	// Without a secret configured, HMAC tokens must not be accepted
+	if _, err := verifier.Verify(signHMAC(t, validClaims("u1"), testSecret)); err == nil {
+		t.Fatal("HMAC token accepted without a configured secret")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	verifier, err := NewVerifier(Options{Secret: testSecret})
+	if err != nil {
+		t.Fatalf("NewVerifier returned error: %v", err)
+	}
+	router := gin.New()
+	router.GET("/whoami", Middleware(verifier), func(c *gin.Context) {
+		claims, _ := FromContext(c.Request.Context())
+		c.String(http.StatusOK, claims.UserID)
+	})
+	token := signHMAC(t, validClaims("u1"), testSecret)
+
+	tests := []struct {
+		name       string
+		target     string
+		header     http.Header
+		wantStatus int
+	}{
+		{name: "missing token", target: "/whoami", wantStatus: http.StatusUnauthorized},
+		{name: "invalid token", target: "/whoami", header: http.Header{"Authorization": {"Bearer nope"}}, wantStatus: http.StatusUnauthorized},
+		{name: "wrong scheme", target: "/whoami", header: http.Header{"Authorization": {"Basic " + token}}, wantStatus: http.StatusUnauthorized},
+		{name: "bearer token", target: "/whoami", header: http.Header{"Authorization": {"Bearer " + token}}, wantStatus: http.StatusOK},
+		{name: "query token on plain request", target: "/whoami?token=" + token, wantStatus: http.StatusUnauthorized},
+		{name: "query token on websocket handshake", target: "/whoami?token=" + token, header: http.Header{"Upgrade": {"websocket"}}, wantStatus: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			for k, v := range tt.header {
+				req.Header[k] = v
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantStatus == http.StatusOK && w.Body.String() != "u1" {
+				t.Fatalf("user = %q, want u1", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestMiddlewareWithoutVerifierNeverGrantsAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/match/status/by-user/:userId", Middleware(nil), func(c *gin.Context) {
+		if !CanActAs(c.Request.Context(), c.Param("userId")) {
+			c.Status(http.StatusForbidden)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	router.GET("/admin/queues", Middleware(nil), RequireAdmin(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for target, want := range map[string]int{
+		"/match/status/by-user/u1": http.StatusOK,
+		"/admin/queues":            http.StatusForbidden,
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != want {
+			t.Errorf("%s status = %d, want %d with authentication disabled", target, w.Code, want)
+		}
+	}
+}
+
+func TestHideQueryToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	verifier, err := NewVerifier(Options{Secret: testSecret})
+	if err != nil {
+		t.Fatalf("NewVerifier returned error: %v", err)
+	}
+	router := gin.New()
+	router.Use(HideQueryToken())
+	router.GET("/match/ws/:userId", Middleware(verifier), func(c *gin.Context) {
+		c.String(http.StatusOK, c.Request.RequestURI)
+	})
+	token := signHMAC(t, validClaims("u1"), testSecret)
+
+	tests := []struct {
+		name       string
+		upgrade    bool
+		wantStatus int
+	}{
+		{name: "websocket handshake", upgrade: true, wantStatus: http.StatusOK},
+		{name: "plain request", wantStatus: http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/match/ws/u1?token="+token+"&v=2", nil)
+			if tt.upgrade {
+				req.Header.Set("Upgrade", "websocket")
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if req.URL.Query().Has("token") || strings.Contains(req.RequestURI, token) {
+				t.Fatalf("request URI %q still carries the token", req.RequestURI)
+			}
+			if tt.wantStatus == http.StatusOK && w.Body.String() != "/match/ws/u1?v=2" {
+				t.Fatalf("handler saw %q, want the other parameters kept", w.Body.String())
+			}
+		})
+	}
+}
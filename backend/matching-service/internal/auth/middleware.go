@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type contextKey struct{}
+
+// WithClaims returns a copy of ctx carrying the caller's claims
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, contextKey{}, claims)
+}
+
+// FromContext returns the claims injected by Middleware, if any
+func FromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(contextKey{}).(*Claims)
+	return claims, ok
+}
+
+// Middleware rejects requests without a valid bearer token and injects the caller's
+// claims into the request context. Browsers cannot set headers on WebSocket
+// handshakes, so those may pass the token in the "token" query parameter instead.
+//
+// A nil verifier disables authentication (AUTH_DISABLED): callers may act for any user,
+// but nobody is an admin.
+func Middleware(verifier *Verifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if verifier == nil {
+			c.Request = c.Request.WithContext(WithClaims(c.Request.Context(), &Claims{Unauthenticated: true}))
+			c.Next()
+			return
+		}
+
+		token := bearerToken(c.Request)
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": ErrMissingToken.Error()})
+			return
+		}
+		claims, err := verifier.Verify(token)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": ErrInvalidToken.Error()})
+			return
+		}
+		c.Request = c.Request.WithContext(WithClaims(c.Request.Context(), claims))
+		c.Next()
+	}
+}
+
+// HideQueryToken moves a token passed in the "token" query parameter into the
+// Authorization header and strips it from the URL, so the token never reaches request
+// logs, traces or panic dumps. Only WebSocket handshakes keep the token; it is dropped
+// from any other request, which may not authenticate that way. It must run before every
+// middleware that records the request.
+func HideQueryToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		r := c.Request
+		query := r.URL.Query()
+		if !query.Has(queryTokenParam) {
+			c.Next()
+			return
+		}
+		if token := query.Get(queryTokenParam); isWebSocketHandshake(r) && token != "" && r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		query.Del(queryTokenParam)
+		r.URL.RawQuery = query.Encode()
+		r.RequestURI = r.URL.RequestURI()
+		c.Next()
+	}
+}
+
+// queryTokenParam carries the token on WebSocket handshakes
+const queryTokenParam = "token"
+
+func isWebSocketHandshake(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+func bearerToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		scheme, token, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") {
+			return ""
+		}
+		return strings.TrimSpace(token)
+	}
+	if isWebSocketHandshake(r) {
+		return r.URL.Query().Get(queryTokenParam)
+	}
+	return ""
+}
+
+// CanActAs reports whether the authenticated caller may act on behalf of userID:
+// either it is their own ID, they are an admin or authentication is disabled.
+func CanActAs(ctx context.Context, userID string) bool {
+	claims, ok := FromContext(ctx)
+	if !ok {
+		return false
+	}
+	return claims.IsAdmin || claims.Unauthenticated || claims.UserID == userID
+}
+
+// RequireAdmin rejects callers whose claims are not an admin's. It must run after Middleware.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if claims, ok := FromContext(c.Request.Context()); !ok || !claims.IsAdmin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin access required"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrMissingToken = errors.New("missing bearer token")
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// Claims is the caller identity carried by a verified token
+type Claims struct {
+	UserID string
+	// IsAdmin is only set for users listed in ADMIN_USER_IDS; tokens cannot make anyone an admin
+	IsAdmin bool
+	// Unauthenticated is set when authentication is disabled. The caller may act for any
+	// user but is never an admin.
+	Unauthenticated bool
+}
+
+// tokenClaims mirrors the payload signed by user-service, which only carries the user ID,
+// in "id". "sub" is accepted too so tokens from a standard issuer also work.
+type tokenClaims struct {
+	ID string `json:"id"`
+	jwt.RegisteredClaims
+}
+
+// Verifier checks JWTs signed with a shared HMAC secret or with a key from a JWKS file
+type Verifier struct {
+	secret   []byte
+	keys     *KeySet
+	adminIDs []string
+}
+
+// Options configures a Verifier. At least one of Secret or JWKSFile must be set.
+type Options struct {
+	// Secret is the HMAC secret shared with user-service (JWT_SECRET there)
+	Secret string
+	// JWKSFile is a local JSON Web Key Set used for RSA and ECDSA signed tokens
+	JWKSFile string
+	// AdminUserIDs are the only admins
+	AdminUserIDs []string
+}
+
+func NewVerifier(opts Options) (*Verifier, error) {
+	if opts.Secret == "" && opts.JWKSFile == "" {
+		return nil, errors.New("either a JWT secret or a JWKS file is required")
+	}
+	v := &Verifier{adminIDs: opts.AdminUserIDs}
+	if opts.Secret != "" {
+		v.secret = []byte(opts.Secret)
+	}
+	if opts.JWKSFile != "" {
+		keys, err := LoadKeySet(opts.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		v.keys = keys
+	}
+	return v, nil
+}
+
+// Verify validates the token's signature and expiry and returns the caller's claims
+func (v *Verifier) Verify(tokenString string) (*Claims, error) {
+	var claims tokenClaims
+	_, err := jwt.ParseWithClaims(tokenString, &claims, v.keyFor,
+		jwt.WithValidMethods(v.validMethods()),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	userID := claims.ID
+	if userID == "" {
+		userID = claims.Subject
+	}
+	if userID == "" {
+		return nil, fmt.Errorf("%w: no user id claim", ErrInvalidToken)
+	}
+	return &Claims{
+		UserID:  userID,
+		IsAdmin: slices.Contains(v.adminIDs, userID),
+	}, nil
+}
+
+func (v *Verifier) validMethods() []string {
+	var methods []string
+	if v.secret != nil {
+		methods = append(methods, "HS256", "HS384", "HS512")
+	}
+	if v.keys != nil {
+		methods = append(methods, "RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512")
+	}
+	return methods
+}
+
+// keyFor picks the verification key by signing method: the secret for HMAC, the JWKS otherwise
+func (v *Verifier) keyFor(token *jwt.Token) (any, error) {
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); ok {
+		return v.secret, nil
+	}
+	kid, _ := token.Header["kid"].(string)
+	return v.keys.Key(kid)
+}
@@ -1,26 +1,196 @@
 package config
 
-import "os"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
 
+// Config is the service's configuration. Every field is named by an environment variable;
+// see Load for where values come from.
 type Config struct {
+	// AppEnv names the deployment, such as dev or production
+	AppEnv             string
 	Port               string
 	RedisURL           string
 	UserServiceURL     string
 	QuestionServiceURL string
+	// RelaxTopicsAfter is how long a user waits before matching anyone sharing a topic
+	RelaxTopicsAfter time.Duration
+	// RelaxDifficultyAfter is how long a user waits before matching an adjacent difficulty
+	RelaxDifficultyAfter time.Duration
+	// MaxQueueWait is how long a user may wait in a queue before being evicted with a timeout
+	MaxQueueWait time.Duration
+	// HeartbeatGrace is how long a waiting user may go without a heartbeat before eviction
+	HeartbeatGrace time.Duration
+	// AcceptTimeout is how long both users have to accept a match; zero skips the handshake
+	AcceptTimeout time.Duration
+	// RequeuePartnerOnCancel puts the partner of a cancelling user back in their queue
+	RequeuePartnerOnCancel bool
+	// MatchmakerInterval is how often the background matchmaker scans the queues
+	MatchmakerInterval time.Duration
+	// OutboundTimeout bounds a single call to user-service or question-service
+	OutboundTimeout time.Duration
+	// OutboundRetries is how many times a failed call to another service is retried
+	OutboundRetries int
+	// BreakerFailureThreshold is how many consecutive failed calls open a dependency's circuit breaker
+	BreakerFailureThreshold int
+	// BreakerOpenFor is how long an open circuit breaker fails calls before probing the dependency again
+	BreakerOpenFor time.Duration
+	// MatchBudget bounds the calls to other services made while forming a match; zero leaves them unbounded
+	MatchBudget time.Duration
+	// MatchHistoryPerUser is how many of their newest matches each user's history keeps
+	MatchHistoryPerUser int
+	// ReadinessProbeServices makes readiness probe user-service and question-service too; they are reported but not required
+	ReadinessProbeServices bool
+	// ReadinessTimeout bounds each readiness probe
+	ReadinessTimeout time.Duration
+	// QuestionCacheTTL is how long candidate questions are cached per difficulty and tag; zero disables the cache
+	QuestionCacheTTL time.Duration
+	// QuestionCacheRefreshInterval is how often cached candidates are re-fetched in the background; zero disables it
+	QuestionCacheRefreshInterval time.Duration
+	// CatalogueTopics replaces the default topic catalogue when set
+	CatalogueTopics []string
+	// CatalogueRefreshInterval is how often topics are refreshed from question-service; zero disables it
+	CatalogueRefreshInterval time.Duration
+	// JWTSecret is the HMAC secret user-service signs access tokens with
+	JWTSecret string
+	// JWKSFile is a local JWKS file for verifying RSA or ECDSA signed tokens
+	JWKSFile string
+	// AuthDisabled runs the match API without authentication, for local use only
+	AuthDisabled bool
+	// AdminUserIDs may act on behalf of any user; it is the only way to become an admin
+	AdminUserIDs []string
+	// AllowedOrigins are the browser origins allowed to call the API; a * in the host matches within one label
+	AllowedOrigins []string
+	// RateLimitMatchPerUser and RateLimitMatchPerIP limit requests a minute to any /match route; zero disables them
+	RateLimitMatchPerUser int
+	RateLimitMatchPerIP   int
+	// RateLimitMatchRequestPerUser and RateLimitMatchRequestPerIP further limit requests a minute to POST /match/request
+	RateLimitMatchRequestPerUser int
+	RateLimitMatchRequestPerIP   int
+	// TrustedProxies are the addresses or CIDR ranges whose X-Forwarded-For is believed when
+	// finding a client's IP; empty trusts none and uses the connection's address
+	TrustedProxies []string
+	// ShutdownDrainTimeout bounds how long shutdown waits for in-flight requests and workers
+	ShutdownDrainTimeout time.Duration
+	// LogLevel is the minimum level logged: debug, info, warn or error
+	LogLevel string
+	// TracingExporter is where spans are sent: none, stdout or otlp
+	TracingExporter string
+	// TracingServiceName identifies this service in traces
+	TracingServiceName string
 }
 
-func Load() Config {
-	return Config{
-		Port:               getEnv("PORT", "8080"),
-		RedisURL:           getEnv("REDIS_URL", "localhost:6379"),
-		UserServiceURL:     getEnv("USER_SERVICE_URL", "http://localhost:3001"),
-		QuestionServiceURL: getEnv("QUESTION_SERVICE_URL", "http://localhost:8080"),
+// productionOrigin is the deployed frontend
+const productionOrigin = "https://cs3219-ay2526s1-project-g11.vercel.app"
+
+// originsKeyPrefix starts the per-environment origin settings, such as CORS_ALLOWED_ORIGINS_PRODUCTION
+const originsKeyPrefix = "CORS_ALLOWED_ORIGINS_"
+
+// envOriginsKey names the setting listing the origins allowed when APP_ENV is appEnv
+func envOriginsKey(appEnv string) string {
+	return originsKeyPrefix + strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, appEnv)
+}
+
+// defaultAllowedOrigins are the origins allowed when neither CORS_ALLOWED_ORIGINS nor the
+// environment's own setting is set. Development also allows the frontend's local dev servers.
+// No pattern can safely match Vercel preview deployments, since anyone can create a project
+// whose deployment names match it, so preview ones must be listed in the settings.
+func defaultAllowedOrigins(appEnv string) []string {
+	switch appEnv {
+	case "production", "preview", "staging":
+		return []string{productionOrigin}
+	default:
+		return []string{productionOrigin, "http://localhost:3000", "http://localhost:5173"}
 	}
 }
 
-func getEnv(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok {
-		return value
+// LoadOptions says which files Load reads besides the environment
+type LoadOptions struct {
+	// File is an optional YAML or TOML config file. When empty, CONFIG_FILE names it.
+	File string
+	// DotEnv is an optional .env file; a missing file is ignored
+	DotEnv string
+}
+
+// Load reads the configuration and validates it. Each setting is taken from the first of
+// these that sets it: environment variables, the .env file, the config file, the default.
+// Variables from the .env file are also exported to the process environment, so libraries
+// reading it directly, such as the OTLP exporter, see them. All problems found are returned
+// together, each naming the setting and where its value came from.
+func Load(opts LoadOptions) (Config, error) {
+	src, err := newSource(opts)
+	if err != nil {
+		return Config{}, err
+	}
+
+	appEnv := src.string("APP_ENV", "dev")
+	cfg := Config{
+		AppEnv:             appEnv,
+		Port:               src.string("PORT", "8080"),
+		RedisURL:           src.string("REDIS_URL", "localhost:6379"),
+		UserServiceURL:     src.string("USER_SERVICE_URL", "http://localhost:3001"),
+		QuestionServiceURL: src.string("QUESTION_SERVICE_URL", "http://localhost:8080"),
+
+		RelaxTopicsAfter:       src.seconds("RELAX_TOPICS_AFTER_SECONDS", 30*time.Second),
+		RelaxDifficultyAfter:   src.seconds("RELAX_DIFFICULTY_AFTER_SECONDS", 60*time.Second),
+		MaxQueueWait:           src.seconds("MAX_QUEUE_WAIT_SECONDS", 10*time.Minute),
+		HeartbeatGrace:         src.seconds("HEARTBEAT_GRACE_SECONDS", 30*time.Second),
+		AcceptTimeout:          src.seconds("ACCEPT_TIMEOUT_SECONDS", 0),
+		RequeuePartnerOnCancel: src.bool("REQUEUE_PARTNER_ON_CANCEL", true),
+		MatchmakerInterval:     src.seconds("MATCHMAKER_INTERVAL_SECONDS", 5*time.Second),
+
+		OutboundTimeout:         src.seconds("OUTBOUND_TIMEOUT_SECONDS", 5*time.Second),
+		OutboundRetries:         src.int("OUTBOUND_RETRIES", 2),
+		BreakerFailureThreshold: src.int("BREAKER_FAILURE_THRESHOLD", 5),
+		BreakerOpenFor:          src.seconds("BREAKER_OPEN_SECONDS", 30*time.Second),
+		MatchBudget:             src.seconds("MATCH_BUDGET_SECONDS", 10*time.Second),
+
+		MatchHistoryPerUser: src.int("MATCH_HISTORY_PER_USER", 200),
+
+		ReadinessProbeServices: src.bool("READINESS_PROBE_SERVICES", false),
+		ReadinessTimeout:       src.seconds("READINESS_TIMEOUT_SECONDS", 2*time.Second),
+
+		QuestionCacheTTL:             src.seconds("QUESTION_CACHE_TTL_SECONDS", 10*time.Minute),
+		QuestionCacheRefreshInterval: src.seconds("QUESTION_CACHE_REFRESH_SECONDS", 5*time.Minute),
+
+		CatalogueTopics:          src.list("CATALOGUE_TOPICS", nil),
+		CatalogueRefreshInterval: src.seconds("CATALOGUE_REFRESH_SECONDS", 0),
+
+		JWTSecret:      src.string("JWT_SECRET", ""),
+		JWKSFile:       src.string("JWT_JWKS_FILE", ""),
+		AuthDisabled:   src.bool("AUTH_DISABLED", false),
+		AdminUserIDs:   src.list("ADMIN_USER_IDS", nil),
+		AllowedOrigins: src.list("CORS_ALLOWED_ORIGINS", src.list(envOriginsKey(appEnv), defaultAllowedOrigins(appEnv))),
+
+		RateLimitMatchPerUser:        src.int("RATE_LIMIT_MATCH_PER_USER", 120),
+		RateLimitMatchPerIP:          src.int("RATE_LIMIT_MATCH_PER_IP", 600),
+		RateLimitMatchRequestPerUser: src.int("RATE_LIMIT_MATCH_REQUEST_PER_USER", 10),
+		RateLimitMatchRequestPerIP:   src.int("RATE_LIMIT_MATCH_REQUEST_PER_IP", 60),
+		TrustedProxies:               src.list("TRUSTED_PROXIES", nil),
+
+		ShutdownDrainTimeout: src.seconds("SHUTDOWN_DRAIN_SECONDS", 20*time.Second),
+
+		LogLevel:           src.string("LOG_LEVEL", "info"),
+		TracingExporter:    src.string("TRACING_EXPORTER", "none"),
+		TracingServiceName: src.string("TRACING_SERVICE_NAME", "matching-service"),
+	}
+
+	// A config file may list origins for every environment; only this one's are read
+	errs := append(src.errs, src.unknownFileKeys(originsKeyPrefix)...)
+	if err := cfg.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		return cfg, fmt.Errorf("invalid configuration:\n%w", errors.Join(errs...))
 	}
-	return fallback
+	return cfg, nil
 }
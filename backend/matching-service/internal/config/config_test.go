@@ -0,0 +1,252 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// unsetEnv removes keys from the environment for the duration of the test
+func unsetEnv(t *testing.T, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+}
+
+// withSecret configures token verification, which Load requires unless AUTH_DISABLED is set
+func withSecret(t *testing.T) {
+	t.Helper()
+	unsetEnv(t, "AUTH_DISABLED", "JWT_JWKS_FILE")
+	t.Setenv("JWT_SECRET", "secret")
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadDefaults(t *testing.T) {
+	unsetEnv(t, "CONFIG_FILE", "APP_ENV", "PORT", "REDIS_URL", "RELAX_TOPICS_AFTER_SECONDS", "CORS_ALLOWED_ORIGINS")
+	withSecret(t)
+
+	cfg, err := Load(LoadOptions{})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.AppEnv != "dev" || cfg.Port != "8080" || cfg.RedisURL != "localhost:6379" || cfg.RelaxTopicsAfter != 30*time.Second {
+		t.Fatalf("unexpected defaults: %+v", cfg)
+	}
+	if strings.Join(cfg.AllowedOrigins, " ") != "https://cs3219-ay2526s1-project-g11.vercel.app http://localhost:3000 http://localhost:5173" {
+		t.Fatalf("AllowedOrigins = %v, want the deployed and local frontends", cfg.AllowedOrigins)
+	}
+}
+
+func TestLoadAllowedOriginsPerEnvironment(t *testing.T) {
+	unsetEnv(t, "CONFIG_FILE", "CORS_ALLOWED_ORIGINS", "CORS_ALLOWED_ORIGINS_PRODUCTION", "CORS_ALLOWED_ORIGINS_PREVIEW")
+	withSecret(t)
+	file := writeFile(t, "config.yaml", `
+cors_allowed_origins:
+  preview:
+    - https://preview-*.example.com
+  production: https://example.com
+`)
+
+	tests := []struct {
+		appEnv string
+		file   string
+		env    string
+		want   string
+	}{
+		{appEnv: "production", want: "https://cs3219-ay2526s1-project-g11.vercel.app"},
+		{appEnv: "preview", want: "https://cs3219-ay2526s1-project-g11.vercel.app"},
+		{appEnv: "preview", file: file, want: "https://preview-*.example.com"},
+		{appEnv: "production", file: file, want: "https://example.com"},
+		{appEnv: "production", file: file, env: "https://override.example.com", want: "https://override.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.appEnv+" "+tt.want, func(t *testing.T) {
+			t.Setenv("APP_ENV", tt.appEnv)
+			if tt.env != "" {
+				t.Setenv("CORS_ALLOWED_ORIGINS", tt.env)
+			}
+			cfg, err := Load(LoadOptions{File: tt.file})
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if got := strings.Join(cfg.AllowedOrigins, " "); got != tt.want {
+				t.Fatalf("AllowedOrigins = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadLayersEnvOverDotEnvOverFile(t *testing.T) {
+	unsetEnv(t, "CONFIG_FILE", "PORT", "REDIS_URL", "RELAX_TOPICS_AFTER_SECONDS", "CORS_ALLOWED_ORIGINS")
+	withSecret(t)
+	file := writeFile(t, "config.yaml", `
+port: 1111
+redis_url: redis:6379
+relax:
+  topics_after_seconds: 45
+cors_allowed_origins:
+  - http://localhost:3000
+  - https://example.com
+`)
+	dotEnv := writeFile(t, ".env", "PORT=2222\nRELAX_TOPICS_AFTER_SECONDS=50\n")
+
+	cfg, err := Load(LoadOptions{File: file, DotEnv: dotEnv})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Port != "2222" || cfg.RelaxTopicsAfter != 50*time.Second {
+		t.Fatalf(".env should override the config file, got port %s and relax after %v", cfg.Port, cfg.RelaxTopicsAfter)
+	}
+	if cfg.RedisURL != "redis:6379" || strings.Join(cfg.AllowedOrigins, " ") != "http://localhost:3000 https://example.com" {
+		t.Fatalf("config file values not applied: %+v", cfg)
+	}
+
+	t.Setenv("PORT", "3333")
+	cfg, err = Load(LoadOptions{File: file, DotEnv: dotEnv})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Port != "3333" {
+		t.Fatalf("environment should override .env, got port %s", cfg.Port)
+	}
+}
+
+func TestLoadReadsTOMLNamedByConfigFile(t *testing.T) {
+	unsetEnv(t, "PORT", "OUTBOUND_RETRIES")
+	withSecret(t)
+	t.Setenv("CONFIG_FILE", writeFile(t, "config.toml", "port = 9090\n\n[outbound]\nretries = 4\n"))
+
+	cfg, err := Load(LoadOptions{})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Port != "9090" || cfg.OutboundRetries != 4 {
+		t.Fatalf("got port %s and %d retries, want 9090 and 4", cfg.Port, cfg.OutboundRetries)
+	}
+}
+
+func TestLoadExportsDotEnvToEnvironment(t *testing.T) {
+	unsetEnv(t, "OTEL_EXPORTER_OTLP_ENDPOINT")
+	withSecret(t)
+	dotEnv := writeFile(t, ".env", "OTEL_EXPORTER_OTLP_ENDPOINT=http://collector:4318\n")
+
+	if _, err := Load(LoadOptions{DotEnv: dotEnv}); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if got := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); got != "http://collector:4318" {
+		t.Fatalf("OTEL_EXPORTER_OTLP_ENDPOINT = %q, want the .env value", got)
+	}
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	unsetEnv(t, "CONFIG_FILE", "OUTBOUND_RETRIES")
+	t.Setenv("PORT", "http")
+	t.Setenv("MATCH_BUDGET_SECONDS", "1.5")
+	file := writeFile(t, "config.yaml", "outbound_retries: -1\nredis_ulr: redis:6379\n")
+
+	_, err := Load(LoadOptions{File: file})
+	if err == nil {
+		t.Fatal("Load() succeeded, want an error")
+	}
+	for _, want := range []string{
+		`PORT: "http" is not a port`,
+		`MATCH_BUDGET_SECONDS: "1.5" (from environment) is not a whole number of seconds`,
+		`OUTBOUND_RETRIES: "-1" (from ` + file + `) is not a non-negative whole number`,
+		"unknown setting REDIS_ULR",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestLoadRejectsMissingConfigFile(t *testing.T) {
+	if _, err := Load(LoadOptions{File: filepath.Join(t.TempDir(), "missing.yaml")}); err == nil {
+		t.Fatal("Load() succeeded with a missing config file")
+	}
+}
+
+func validConfig() Config {
+	return Config{
+		AppEnv:                       "dev",
+		Port:                         "8080",
+		RedisURL:                     "localhost:6379",
+		UserServiceURL:               "http://localhost:3001",
+		QuestionServiceURL:           "https://questions.example.com/api",
+		RelaxTopicsAfter:             30 * time.Second,
+		RelaxDifficultyAfter:         60 * time.Second,
+		OutboundTimeout:              5 * time.Second,
+		BreakerFailureThreshold:      5,
+		BreakerOpenFor:               30 * time.Second,
+		MatchHistoryPerUser:          200,
+		ReadinessTimeout:             2 * time.Second,
+		QuestionCacheTTL:             10 * time.Minute,
+		QuestionCacheRefreshInterval: 5 * time.Minute,
+		AllowedOrigins:               []string{"http://localhost:3000"},
+		ShutdownDrainTimeout:         20 * time.Second,
+		JWTSecret:                    "secret",
+		LogLevel:                     "info",
+		TracingExporter:              "none",
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   string
+	}{
+		{name: "valid", modify: func(*Config) {}},
+		{name: "port out of range", modify: func(c *Config) { c.Port = "70000" }, want: "PORT"},
+		{name: "redis address without port", modify: func(c *Config) { c.RedisURL = "redis" }, want: "REDIS_URL"},
+		{name: "service URL without scheme", modify: func(c *Config) { c.UserServiceURL = "localhost:3001" }, want: "USER_SERVICE_URL"},
+		{name: "difficulty relaxed before topics", modify: func(c *Config) { c.RelaxDifficultyAfter = 10 * time.Second }, want: "RELAX_DIFFICULTY_AFTER_SECONDS"},
+		{name: "cache refreshed after expiry", modify: func(c *Config) { c.QuestionCacheRefreshInterval = time.Hour }, want: "QUESTION_CACHE_REFRESH_SECONDS"},
+		{name: "cache disabled ignores refresh", modify: func(c *Config) { c.QuestionCacheTTL = 0 }},
+		{name: "unbounded history", modify: func(c *Config) { c.MatchHistoryPerUser = 0 }, want: "MATCH_HISTORY_PER_USER"},
+		{name: "origin with path", modify: func(c *Config) { c.AllowedOrigins = []string{"https://example.com/app"} }, want: "CORS_ALLOWED_ORIGINS"},
+		{name: "wildcard origin", modify: func(c *Config) { c.AllowedOrigins = []string{"https://*.example.com"} }},
+		{name: "wildcard under a public suffix", modify: func(c *Config) { c.AllowedOrigins = []string{"https://*.vercel.app"} }, want: "CORS_ALLOWED_ORIGINS"},
+		{name: "no origins", modify: func(c *Config) { c.AllowedOrigins = nil }, want: "CORS_ALLOWED_ORIGINS"},
+		{name: "no auth", modify: func(c *Config) { c.JWTSecret = "" }, want: "JWT_SECRET or JWT_JWKS_FILE is required"},
+		{name: "auth disabled locally", modify: func(c *Config) { c.JWTSecret = ""; c.AuthDisabled = true }},
+		{name: "auth disabled in production", modify: func(c *Config) { c.JWTSecret = ""; c.AuthDisabled = true; c.AppEnv = "production" }, want: "AUTH_DISABLED"},
+		{name: "auth disabled with a secret", modify: func(c *Config) { c.AuthDisabled = true }, want: "AUTH_DISABLED"},
+		{name: "production with secret", modify: func(c *Config) { c.AppEnv = "production" }},
+		{name: "admins without auth", modify: func(c *Config) { c.JWTSecret = ""; c.AuthDisabled = true; c.AdminUserIDs = []string{"admin"} }, want: "ADMIN_USER_IDS"},
+		{name: "missing JWKS file", modify: func(c *Config) { c.JWKSFile = "/nonexistent/jwks.json" }, want: "JWT_JWKS_FILE"},
+		{name: "rate limit too high", modify: func(c *Config) { c.RateLimitMatchPerIP = 100000 }, want: "RATE_LIMIT_MATCH_PER_IP"},
+		{name: "trusted proxy range", modify: func(c *Config) { c.TrustedProxies = []string{"169.254.0.0/16", "10.0.0.1"} }},
+		{name: "trusted proxy hostname", modify: func(c *Config) { c.TrustedProxies = []string{"proxy.internal"} }, want: "TRUSTED_PROXIES"},
+		{name: "unknown log level", modify: func(c *Config) { c.LogLevel = "verbose" }, want: "LOG_LEVEL"},
+		{name: "unknown exporter", modify: func(c *Config) { c.TracingExporter = "jaeger" }, want: "TRACING_EXPORTER"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(&cfg)
+			err := cfg.Validate()
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Validate() error = %v, want it to mention %s", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// layer is one place settings are read from, keyed by environment variable name
+type layer struct {
+	name   string
+	values map[string]string
+}
+
+// source looks settings up in its layers, highest precedence first, and collects the
+// problems found parsing them so they can be reported together
+type source struct {
+	layers []layer
+	// file is the config file's layer, if there is one
+	file *layer
+	// read records every key looked up, so unknown keys in the config file can be reported
+	read map[string]bool
+	errs []error
+}
+
+func newSource(opts LoadOptions) (*source, error) {
+	environment := layer{name: "environment", values: map[string]string{}}
+	for _, entry := range os.Environ() {
+		if key, value, ok := strings.Cut(entry, "="); ok {
+			environment.values[key] = value
+		}
+	}
+	src := &source{layers: []layer{environment}, read: map[string]bool{}}
+
+	if opts.DotEnv != "" {
+		values, err := godotenv.Read(opts.DotEnv)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("reading %s: %w", opts.DotEnv, err)
+		}
+		for key, value := range values {
+			if _, ok := environment.values[key]; !ok {
+				os.Setenv(key, value)
+			}
+		}
+		src.layers = append(src.layers, layer{name: opts.DotEnv, values: values})
+	}
+
+	path := opts.File
+	if path == "" {
+		path, _ = src.lookup("CONFIG_FILE")
+	}
+	if path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			return nil, err
+		}
+		src.layers = append(src.layers, layer{name: path, values: values})
+		src.file = &src.layers[len(src.layers)-1]
+	}
+	return src, nil
+}
+
+// readFile reads a YAML or TOML config file. Keys are the environment variable names in any
+// case, and nested tables join their keys with underscores, so relax: {topics_after_seconds: 30}
+// sets RELAX_TOPICS_AFTER_SECONDS. Lists may be written as arrays.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+	tree := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("config file %s: unsupported format, want .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	values := map[string]string{}
+	if err := flatten("", tree, values); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+func flatten(prefix string, tree map[string]any, values map[string]string) error {
+	for name, value := range tree {
+		key := strings.ToUpper(name)
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+		switch value := value.(type) {
+		case map[string]any:
+			if err := flatten(key, value, values); err != nil {
+				return err
+			}
+		case []any:
+			items := make([]string, len(value))
+			for i, item := range value {
+				switch item.(type) {
+				case map[string]any, []any:
+					return fmt.Errorf("%s: list items must be plain values", key)
+				}
+				items[i] = fmt.Sprint(item)
+			}
+			values[key] = strings.Join(items, ",")
+		case nil:
+			values[key] = ""
+		default:
+			values[key] = fmt.Sprint(value)
+		}
+	}
+	return nil
+}
+
+// lookup returns the value of key from the first layer that sets it
+func (s *source) lookup(key string) (value, from string) {
+	s.read[key] = true
+	for _, l := range s.layers {
+		if value, ok := l.values[key]; ok {
+			return value, l.name
+		}
+	}
+	return "", ""
+}
+
+// invalid records a value that could not be parsed
+func (s *source) invalid(key, value, from, want string) {
+	s.errs = append(s.errs, fmt.Errorf("%s: %q (from %s) is not %s", key, value, from, want))
+}
+
+func (s *source) string(key, fallback string) string {
+	if value, from := s.lookup(key); from != "" {
+		return value
+	}
+	return fallback
+}
+
+// seconds reads a whole number of seconds; blank values use the fallback
+func (s *source) seconds(key string, fallback time.Duration) time.Duration {
+	value, from := s.lookup(key)
+	if value = strings.TrimSpace(value); value == "" {
+		return fallback
+	}
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		s.invalid(key, value, from, "a whole number of seconds")
+		return fallback
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// int reads a non-negative integer; blank values use the fallback
+func (s *source) int(key string, fallback int) int {
+	value, from := s.lookup(key)
+	if value = strings.TrimSpace(value); value == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		s.invalid(key, value, from, "a non-negative whole number")
+		return fallback
+	}
+	return parsed
+}
+
+// bool reads a boolean such as "true" or "0"; blank values use the fallback
+func (s *source) bool(key string, fallback bool) bool {
+	value, from := s.lookup(key)
+	if value = strings.TrimSpace(value); value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		s.invalid(key, value, from, "true or false")
+		return fallback
+	}
+	return parsed
+}
+
+// list reads a comma separated list, ignoring blank entries. An unset key uses the fallback;
+// a key set to nothing is an empty list.
+func (s *source) list(key string, fallback []string) []string {
+	value, from := s.lookup(key)
+	if from == "" {
+		return fallback
+	}
+	var values []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+// unknownFileKeys reports config file settings that Load never read, which are most likely
+// typos. Keys starting with one of the known prefixes are never reported.
+func (s *source) unknownFileKeys(knownPrefixes ...string) []error {
+	if s.file == nil {
+		return nil
+	}
+	var keys []string
+	for key := range s.file.values {
+		if !s.read[key] && !hasAnyPrefix(key, knownPrefixes) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	errs := make([]error, len(keys))
+	for i, key := range keys {
+		errs[i] = fmt.Errorf("%s: unknown setting %s", s.file.name, key)
+	}
+	return errs
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"matching-service/internal/cors"
+	"matching-service/internal/tracing"
+)
+
+// maxRequestsPerMinute keeps rate limit buckets refilling at most once a millisecond
+const maxRequestsPerMinute = 60000
+
+// Validate reports every setting that is out of range or inconsistent with another
+func (c Config) Validate() error {
+	var errs []error
+	fail := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		fail("PORT: %q is not a port between 1 and 65535", c.Port)
+	}
+	if _, _, err := net.SplitHostPort(c.RedisURL); err != nil {
+		fail("REDIS_URL: %q is not a host:port address", c.RedisURL)
+	}
+	if err := checkServiceURL(c.UserServiceURL); err != nil {
+		fail("USER_SERVICE_URL: %v", err)
+	}
+	if err := checkServiceURL(c.QuestionServiceURL); err != nil {
+		fail("QUESTION_SERVICE_URL: %v", err)
+	}
+
+	if c.RelaxDifficultyAfter < c.RelaxTopicsAfter {
+		fail("RELAX_DIFFICULTY_AFTER_SECONDS (%v) must not be less than RELAX_TOPICS_AFTER_SECONDS (%v); difficulty is only relaxed once topics are",
+			c.RelaxDifficultyAfter, c.RelaxTopicsAfter)
+	}
+	if c.OutboundTimeout <= 0 {
+		fail("OUTBOUND_TIMEOUT_SECONDS must be at least 1")
+	}
+	if c.BreakerFailureThreshold < 1 {
+		fail("BREAKER_FAILURE_THRESHOLD must be at least 1")
+	}
+	if c.BreakerOpenFor <= 0 {
+		fail("BREAKER_OPEN_SECONDS must be at least 1")
+	}
+	if c.MatchHistoryPerUser < 1 {
+		fail("MATCH_HISTORY_PER_USER must be at least 1")
+	}
+	if c.ReadinessTimeout <= 0 {
+		fail("READINESS_TIMEOUT_SECONDS must be at least 1")
+	}
+	if c.ShutdownDrainTimeout <= 0 {
+		fail("SHUTDOWN_DRAIN_SECONDS must be at least 1")
+	}
+	if c.QuestionCacheTTL > 0 && c.QuestionCacheRefreshInterval >= c.QuestionCacheTTL {
+		fail("QUESTION_CACHE_REFRESH_SECONDS (%v) must be less than QUESTION_CACHE_TTL_SECONDS (%v), or cached questions expire before they are refreshed",
+			c.QuestionCacheRefreshInterval, c.QuestionCacheTTL)
+	}
+
+	for _, limit := range []struct {
+		key       string
+		perMinute int
+	}{
+		{"RATE_LIMIT_MATCH_PER_USER", c.RateLimitMatchPerUser},
+		{"RATE_LIMIT_MATCH_PER_IP", c.RateLimitMatchPerIP},
+		{"RATE_LIMIT_MATCH_REQUEST_PER_USER", c.RateLimitMatchRequestPerUser},
+		{"RATE_LIMIT_MATCH_REQUEST_PER_IP", c.RateLimitMatchRequestPerIP},
+	} {
+		if limit.perMinute > maxRequestsPerMinute {
+			fail("%s: %d is more than %d requests a minute; use 0 to disable the limit", limit.key, limit.perMinute, maxRequestsPerMinute)
+		}
+	}
+
+	for _, proxy := range c.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			fail("TRUSTED_PROXIES: %q is not an IP address or CIDR range", proxy)
+		}
+	}
+
+	if _, err := cors.NewPolicy(c.AllowedOrigins); err != nil {
+		fail("CORS_ALLOWED_ORIGINS: %v", err)
+	}
+
+	if c.JWKSFile != "" {
+		if _, err := os.Stat(c.JWKSFile); err != nil {
+			fail("JWT_JWKS_FILE: %v", err)
+		}
+	}
+	authenticated := c.JWTSecret != "" || c.JWKSFile != ""
+	switch {
+	case c.AuthDisabled && c.AppEnv == "production":
+		fail("AUTH_DISABLED: the match API cannot run unauthenticated in production")
+	case c.AuthDisabled && authenticated:
+		fail("AUTH_DISABLED: unset it to verify tokens with JWT_SECRET or JWT_JWKS_FILE")
+	case !c.AuthDisabled && !authenticated:
+		fail("JWT_SECRET or JWT_JWKS_FILE is required; set AUTH_DISABLED=true to run the match API unauthenticated locally")
+	}
+	if !authenticated && len(c.AdminUserIDs) > 0 {
+		fail("ADMIN_USER_IDS needs JWT_SECRET or JWT_JWKS_FILE to identify admins")
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		fail("LOG_LEVEL: %q is not debug, info, warn or error", c.LogLevel)
+	}
+	switch strings.ToLower(c.TracingExporter) {
+	case tracing.ExporterNone, tracing.ExporterStdout, tracing.ExporterOTLP:
+	default:
+		fail("TRACING_EXPORTER: %q is not none, stdout or otlp", c.TracingExporter)
+	}
+	return errors.Join(errs...)
+}
+
+// checkServiceURL accepts absolute http and https URLs
+func checkServiceURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an http or https URL", raw)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package constants
+
+// Difficulty levels for LeetCode questions, easiest first
+type Difficulty string
+
+const (
+	Easy   Difficulty = "easy"
+	Medium Difficulty = "medium"
+	Hard   Difficulty = "hard"
+)
+
+// Difficulties lists every difficulty, easiest first
+var Difficulties = []Difficulty{Easy, Medium, Hard}
+
+// LeetCode topics, by tag slug
+type Topic string
+
+const (
+	Array              Topic = "array"
+	HashTable          Topic = "hash-table"
+	TwoPointers        Topic = "two-pointers"
+	SlidingWindow      Topic = "sliding-window"
+	Stack              Topic = "stack"
+	Queue              Topic = "queue"
+	Tree               Topic = "tree"
+	Graph              Topic = "graph"
+	DynamicProgramming Topic = "dynamic-programming"
+	Backtracking       Topic = "backtracking"
+	Greedy             Topic = "greedy"
+	BinarySearch       Topic = "binary-search"
+)
+
+// DefaultTopics is the topic catalogue used until one is configured or fetched from
+// question-service. It holds the LeetCode tag slugs the frontend offers.
+var DefaultTopics = []Topic{
+	Array, HashTable, TwoPointers, SlidingWindow, Stack, Queue, Tree, Graph,
+	DynamicProgramming, Backtracking, Greedy, BinarySearch,
+	"string", "math", "sorting", "depth-first-search", "breadth-first-search", "database",
+	"matrix", "binary-tree", "bit-manipulation", "heap-priority-queue", "prefix-sum",
+	"simulation", "design", "counting", "union-find", "enumeration", "linked-list",
+	"doubly-linked-list", "ordered-set", "monotonic-stack", "monotonic-queue", "number-theory",
+	"trie", "segment-tree", "binary-indexed-tree", "binary-search-tree", "recursion",
+	"divide-and-conquer", "combinatorics", "bitmask", "memoization", "geometry",
+	"hash-function", "topological-sort", "string-matching", "shortest-path", "rolling-hash",
+	"game-theory", "interactive", "data-stream", "brainteaser", "randomized", "iterator",
+	"concurrency", "probability-and-statistics", "quickselect", "suffix-array", "line-sweep",
+	"minimum-spanning-tree", "merge-sort", "bucket-sort", "counting-sort", "radix-sort",
+	"shell", "reservoir-sampling", "eulerian-circuit", "strongly-connected-component",
+	"rejection-sampling", "biconnected-component",
+}
@@ -8,9 +8,48 @@ const (
 	UserKeyPrefix        = "user"
 	UserQueueKeySuffix   = "queue"
 	UserMatchIDKeySuffix = "matchId"
+	UserTimeoutKeySuffix = "timeout" // Set when a user is evicted from their queue after waiting too long
+	UserAliveKeySuffix   = "alive"   // Refreshed by heartbeats while a user is waiting
+	UserEventsSuffix     = "events"  // Pub/sub channel suffix for per-user match events
+)
+
+// Queue index constants
+const (
+	ActiveQueuesKey = "queues:active" // Set of queue keys that may hold users, so queues are listed without scanning the keyspace
+)
+
+// Match history constants
+const (
+	HistoryRecordsKey    = "history:matches" // Hash of matchId -> finished match record
+	UserHistoryKeySuffix = "history"         // user:<id>:history is a sorted set of the user's matchIds by match time
+)
+
+// Admin constants
+const (
+	AuditLogKey = "audit:admin" // Stream of admin actions, oldest first
+)
+
+// Match acceptance constants
+const (
+	PendingAcceptKey = "matches:pending_accept" // Sorted set of pending matchIds scored by accept deadline
+)
+
+// Background matchmaker constants
+const (
+	MatchmakerLeaseKey = "matchmaker:lease" // Held by the single replica running the matchmaker
 )
 
 // Redis scan constants
 const (
 	ScanBatchSize = 100 // Number of keys to scan per iteration
 )
+
+// Question cache constants
+const (
+	QuestionCacheKeyPrefix = "questions" // questions:<difficulty>:<tag> holds a pool of candidate questions
+)
+
+// Rate limiting constants
+const (
+	RateLimitKeyPrefix = "ratelimit" // ratelimit:<route>:<user|ip>:<id> holds a token bucket
+)
@@ -0,0 +1,123 @@
+package cors
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"matching-service/internal/logging"
+
+	gincors "github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/publicsuffix"
+)
+
+// hostLabel is what a * in an origin pattern matches: one DNS label, or part of one
+const hostLabel = `[a-z0-9-]+`
+
+// Policy decides which browser origins may call the API
+type Policy struct {
+	origins  map[string]bool
+	patterns []*regexp.Regexp
+}
+
+// NewPolicy parses allowed origins such as https://example.com or http://localhost:3000.
+// A * in the host matches within a single label, so https://*.example.com allows
+// https://a.example.com but neither https://example.com nor https://a.b.example.com. A
+// wildcard directly under a public suffix such as com or vercel.app is refused, whatever
+// text surrounds it: anyone can register a name there that matches, so origins on a hosting
+// platform such as https://app-abc123-team.vercel.app must be listed one by one.
+func NewPolicy(origins []string) (*Policy, error) {
+	if len(origins) == 0 {
+		return nil, fmt.Errorf("at least one origin is required")
+	}
+	p := &Policy{origins: map[string]bool{}}
+	for _, origin := range origins {
+		origin = strings.ToLower(origin)
+		if err := checkOrigin(origin); err != nil {
+			return nil, err
+		}
+		if !strings.Contains(origin, "*") {
+			p.origins[origin] = true
+			continue
+		}
+		pattern := strings.ReplaceAll(regexp.QuoteMeta(origin), `\*`, hostLabel)
+		p.patterns = append(p.patterns, regexp.MustCompile("^"+pattern+"$"))
+	}
+	return p, nil
+}
+
+// Allowed reports whether a request from origin may be served
+func (p *Policy) Allowed(origin string) bool {
+	origin = strings.ToLower(origin)
+	if p.origins[origin] {
+		return true
+	}
+	for _, pattern := range p.patterns {
+		if pattern.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware answers preflight requests and adds CORS headers for allowed origins. Requests
+// from other origins are refused with 403 and logged.
+func Middleware(p *Policy) gin.HandlerFunc {
+	return gincors.New(gincors.Config{
+		AllowOriginWithContextFunc: func(c *gin.Context, origin string) bool {
+			if p.Allowed(origin) {
+				return true
+			}
+			slog.WarnContext(c.Request.Context(), "refused request from disallowed origin",
+				"origin", origin,
+				"method", c.Request.Method,
+				"path", c.Request.URL.Path,
+			)
+			return false
+		},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", logging.RequestIDHeader},
+		ExposeHeaders:    []string{"Content-Length", "Retry-After", logging.RequestIDHeader},
+		AllowCredentials: true,
+		MaxAge:           12 * time.Hour,
+	})
+}
+
+// checkOrigin accepts a scheme and host with an optional port, as browsers send in Origin.
+// Wildcards may only appear in the host, under a domain someone owns.
+func checkOrigin(origin string) error {
+	invalid := fmt.Errorf("%q is not an origin such as https://example.com or https://*.example.com", origin)
+	u, err := url.Parse(origin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+		u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return invalid
+	}
+	if strings.Contains(u.Port(), "*") {
+		return invalid
+	}
+	labels := strings.Split(u.Hostname(), ".")
+	for i, label := range labels {
+		if strings.Contains(label, "*") && !ownedWildcard(strings.Join(labels[i+1:], ".")) {
+			return fmt.Errorf("%q matches sites anyone can register; put the wildcard under a domain you own, as in https://*.example.com, "+
+				"or list each origin on a hosting platform such as vercel.app", origin)
+		}
+	}
+	return nil
+}
+
+// ownedWildcard reports whether a wildcard label under parent only matches one owner's
+// sites. Under a registered domain every label belongs to its owner. Directly under a
+// public suffix, whether a TLD or a hosting platform's, names are handed out to whoever
+// asks first, and fixed text around the wildcard does not change that: on vercel.app anyone
+// can name a project so its deployments end in another team's slug.
+func ownedWildcard(parent string) bool {
+	if parent == "" {
+		return false
+	}
+	suffix, _ := publicsuffix.PublicSuffix(parent)
+	return suffix != parent
+}
@@ -0,0 +1,161 @@
+package cors
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"matching-service/internal/logging"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestNewPolicyRejectsInvalidOrigins(t *testing.T) {
+	for _, origin := range []string{
+		"*",
+		"localhost:3000",
+		"https://example.com/app",
+		"https://*.com",
+		"https://*.co.uk",
+		"https://*.vercel.app",
+		"https://app-*.vercel.app",
+		"https://*-team.vercel.app",
+		"https://app-*-team.vercel.app",
+		"https://cs3219-ay2526s1-project-g11-*-team.vercel.app",
+		"https://app-*-team.com",
+		"https://example.*",
+		"https://example.com:*",
+	} {
+		if _, err := NewPolicy([]string{origin}); err == nil {
+			t.Errorf("NewPolicy(%q) succeeded, want an error", origin)
+		}
+	}
+	if _, err := NewPolicy(nil); err == nil {
+		t.Error("NewPolicy(nil) succeeded, want an error")
+	}
+}
+
+func TestPolicyAllowed(t *testing.T) {
+	policy, err := NewPolicy([]string{
+		"http://localhost:3000",
+		"https://*.example.com",
+		"https://app-abc123-team.vercel.app",
+		"https://app-git-feature-team.vercel.app",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{origin: "http://localhost:3000", want: true},
+		{origin: "HTTP://LOCALHOST:3000", want: true},
+		{origin: "http://localhost:5173"},
+		{origin: "https://localhost:3000"},
+		{origin: "https://preview.example.com", want: true},
+		{origin: "https://example.com"},
+		{origin: "https://a.b.example.com"},
+		{origin: "https://evil.com.example.com.attacker.io"},
+		{origin: "https://app-abc123-team.vercel.app", want: true},
+		{origin: "https://app-git-feature-team.vercel.app", want: true},
+		{origin: "https://app-def456-team.vercel.app"},
+		{origin: "https://app-evil-team.vercel.app"},
+		{origin: "https://other-app.vercel.app"},
+		{origin: "https://app--team.vercel.app"},
+	}
+	for _, tt := range tests {
+		if got := policy.Allowed(tt.origin); got != tt.want {
+			t.Errorf("Allowed(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestMiddlewarePreflight(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	policy, err := NewPolicy([]string{"http://localhost:3000", "https://cs3219-ay2526s1-project-g11-abc123-team.vercel.app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := gin.New()
+	router.Use(logging.Middleware(), Middleware(policy))
+	router.POST("/match/request", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(logging.New(&logs, slog.LevelInfo))
+	t.Cleanup(func() { slog.SetDefault(defaultLogger) })
+
+	tests := []struct {
+		name       string
+		origin     string
+		wantStatus int
+		wantAllow  bool
+	}{
+		{name: "local development", origin: "http://localhost:3000", wantStatus: http.StatusNoContent, wantAllow: true},
+		{name: "preview deployment", origin: "https://cs3219-ay2526s1-project-g11-abc123-team.vercel.app", wantStatus: http.StatusNoContent, wantAllow: true},
+		{name: "another project ending in the team", origin: "https://cs3219-ay2526s1-project-g11-evil-team.vercel.app", wantStatus: http.StatusForbidden},
+		{name: "disallowed origin", origin: "https://evil.example", wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs.Reset()
+			req := httptest.NewRequest(http.MethodOptions, "/match/request", nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			req.Header.Set("Access-Control-Request-Headers", "Authorization, Content-Type")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			allowOrigin := w.Header().Get("Access-Control-Allow-Origin")
+			if !tt.wantAllow {
+				if allowOrigin != "" {
+					t.Fatalf("Access-Control-Allow-Origin = %q for a disallowed origin", allowOrigin)
+				}
+				if !strings.Contains(logs.String(), "refused request from disallowed origin") || !strings.Contains(logs.String(), tt.origin) {
+					t.Fatalf("disallowed origin was not logged: %s", logs.String())
+				}
+				return
+			}
+			if allowOrigin != tt.origin {
+				t.Fatalf("Access-Control-Allow-Origin = %q, want %q", allowOrigin, tt.origin)
+			}
+			if w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+				t.Fatal("credentials should be allowed")
+			}
+			if !strings.Contains(w.Header().Get("Access-Control-Allow-Methods"), http.MethodPost) {
+				t.Fatalf("Access-Control-Allow-Methods = %q", w.Header().Get("Access-Control-Allow-Methods"))
+			}
+			if !strings.Contains(w.Header().Get("Access-Control-Allow-Headers"), "Authorization") {
+				t.Fatalf("Access-Control-Allow-Headers = %q", w.Header().Get("Access-Control-Allow-Headers"))
+			}
+			if w.Header().Get("Access-Control-Max-Age") != "43200" {
+				t.Fatalf("Access-Control-Max-Age = %q, want 12 hours", w.Header().Get("Access-Control-Max-Age"))
+			}
+		})
+	}
+}
+
+func TestMiddlewareAllowsRequestsWithoutOrigin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	policy, err := NewPolicy([]string{"http://localhost:3000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := gin.New()
+	router.Use(Middleware(policy))
+	router.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 for a request from outside a browser", w.Code)
+	}
+}
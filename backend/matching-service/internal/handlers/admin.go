@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"matching-service/internal/auth"
+	"matching-service/internal/models"
+	"matching-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 1000
+)
+
+// RegisterAdminRoutes mounts the queue management API. Every route requires an admin token,
+// so with a nil verifier every call is refused.
+func RegisterAdminRoutes(router *gin.Engine, service *services.MatchingService, verifier *auth.Verifier) {
+	h := &Handler{service: service}
+
+	admin := router.Group("/admin", auth.Middleware(verifier), auth.RequireAdmin())
+	{
+		admin.GET("/queues", h.QueueSummary)
+		admin.GET("/queues/users", h.ListQueueUsers)
+		admin.DELETE("/queues/users/:userId", h.RemoveQueuedUser)
+		admin.POST("/queues/drain", h.DrainQueue)
+		admin.POST("/matches", h.ForcePair)
+		admin.DELETE("/matches/:matchId", h.AdminCancelMatch)
+		admin.GET("/audit", h.AuditLog)
+	}
+}
+
+// actor is the admin performing the request, as recorded in the audit log
+func actor(c *gin.Context) string {
+	if claims, ok := auth.FromContext(c.Request.Context()); ok && claims.UserID != "" {
+		return claims.UserID
+	}
+	return "anonymous"
+}
+
+func (h *Handler) QueueSummary(c *gin.Context) {
+	summary, err := h.service.QueueSummary(c.Request.Context(), actor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+func (h *Handler) ListQueueUsers(c *gin.Context) {
+	users, err := h.service.ListQueueUsers(c.Request.Context(), actor(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, users)
+}
+
+func (h *Handler) RemoveQueuedUser(c *gin.Context) {
+	userId := c.Param("userId")
+	queueKey, err := h.service.RemoveQueuedUser(c.Request.Context(), actor(c), userId)
+	if err != nil {
+		c.JSON(adminErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "removed", "userId": userId, "queue": queueKey})
+}
+
+func (h *Handler) DrainQueue(c *gin.Context) {
+	var req models.DrainQueueRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	removed, err := h.service.DrainQueue(c.Request.Context(), actor(c), req.Queue)
+	if err != nil {
+		c.JSON(adminErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, models.DrainQueueResponse{Queue: req.Queue, Removed: removed})
+}
+
+func (h *Handler) ForcePair(c *gin.Context) {
+	var req models.ForcePairRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	res, err := h.service.ForcePair(c.Request.Context(), actor(c), req.UserIDs)
+	if err != nil {
+		c.JSON(adminErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) AdminCancelMatch(c *gin.Context) {
+	matchId := c.Param("matchId")
+	if err := h.service.AdminCancelMatch(c.Request.Context(), actor(c), matchId); err != nil {
+		c.JSON(adminErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "cancelled", "matchId": matchId})
+}
+
+func (h *Handler) AuditLog(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultAuditLimit)))
+	if err != nil || limit < 1 || limit > maxAuditLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 1000"})
+		return
+	}
+	entries, err := h.service.RecentAuditEntries(c.Request.Context(), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+func adminErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrUserNotQueued), errors.Is(err, services.ErrMatchNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidQueue), errors.Is(err, services.ErrInvalidPair):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrNoSuitableQuestion), errors.Is(err, services.ErrPairNoLongerQueued):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrQuestionsUnavailable):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
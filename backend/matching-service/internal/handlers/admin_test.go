@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAdminRoutesRequireAdmin(t *testing.T) {
+	router := newTestRouter(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		caller     string
+		wantStatus int
+	}{
+		{name: "no token", method: http.MethodGet, target: "/admin/queues", wantStatus: http.StatusUnauthorized},
+		{name: "regular user", method: http.MethodGet, target: "/admin/queues", caller: "u1", wantStatus: http.StatusForbidden},
+		{name: "summary", method: http.MethodGet, target: "/admin/queues", caller: "admin", wantStatus: http.StatusOK},
+		{name: "queue users", method: http.MethodGet, target: "/admin/queues/users", caller: "admin", wantStatus: http.StatusOK},
+		{name: "remove user not queued", method: http.MethodDelete, target: "/admin/queues/users/u1", caller: "admin", wantStatus: http.StatusNotFound},
+		{name: "drain malformed queue", method: http.MethodPost, target: "/admin/queues/drain", body: `{"queue":"nope"}`, caller: "admin", wantStatus: http.StatusBadRequest},
+		{name: "drain queue", method: http.MethodPost, target: "/admin/queues/drain", body: `{"queue":"queue:easy:array"}`, caller: "admin", wantStatus: http.StatusOK},
+		{name: "pair needs two users", method: http.MethodPost, target: "/admin/matches", body: `{"userIds":["u1"]}`, caller: "admin", wantStatus: http.StatusBadRequest},
+		{name: "cancel unknown match", method: http.MethodDelete, target: "/admin/matches/m1", caller: "admin", wantStatus: http.StatusNotFound},
+		{name: "audit log", method: http.MethodGet, target: "/admin/audit", caller: "admin", wantStatus: http.StatusOK},
+		{name: "audit log as user", method: http.MethodGet, target: "/admin/audit", caller: "u1", wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.caller != "" {
+				req.Header.Set("Authorization", "Bearer "+tokenFor(t, tt.caller))
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
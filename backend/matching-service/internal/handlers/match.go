@@ -1,26 +1,43 @@
 package handlers
 
 import (
+	"errors"
+	"matching-service/internal/auth"
+	"matching-service/internal/cors"
 	"matching-service/internal/models"
+	"matching-service/internal/ratelimit"
 	"matching-service/internal/services"
+	"matching-service/internal/validation"
 	"net/http"
+	"slices"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 )
 
 type Handler struct {
-	service *services.MatchingService
+	service   *services.MatchingService
+	catalogue *validation.Catalogue
+	upgrader  *websocket.Upgrader
 }
 
-func RegisterRoutes(router *gin.Engine, service *services.MatchingService) {
-	h := &Handler{service: service}
+// RegisterRoutes mounts the match API. Every route requires a token accepted by verifier;
+// a nil verifier leaves the API unauthenticated, though never with admin rights. Match requests are checked against catalogue,
+// and WebSocket connections against the origins allowed by the CORS policy.
+func RegisterRoutes(router *gin.Engine, service *services.MatchingService, verifier *auth.Verifier, catalogue *validation.Catalogue, limiter *ratelimit.Limiter, origins *cors.Policy) {
+	h := &Handler{service: service, catalogue: catalogue, upgrader: newUpgrader(origins)}
 
-	api := router.Group("/match")
+	api := router.Group("/match", auth.Middleware(verifier), limiter.Middleware(ratelimit.RouteMatch))
 	{
-		api.POST("/request", h.RequestMatch)
+		api.POST("/request", limiter.Middleware(ratelimit.RouteMatchRequest), h.RequestMatch)
 		api.GET("/status/:id", h.MatchStatus) // example extension
 		api.GET("/status/by-user/:userId", h.MatchStatusByUser)
-		api.GET("/queue", h.GetQueue)
+		api.GET("/ws/:userId", h.MatchEvents)
+		api.POST("/heartbeat/:userId", h.Heartbeat)
+		api.POST("/:matchId/accept", h.AcceptMatch)
+		api.POST("/:matchId/decline", h.DeclineMatch)
+		api.GET("/history/:userId", h.MatchHistory)
 		api.DELETE("/cancel/:id", h.CancelMatch)
 		api.DELETE("/cancel/by-user/:userId", h.CancelMatchByUser)
 	}
@@ -32,8 +49,21 @@ func (h *Handler) RequestMatch(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if err := validation.NormalizeMatchRequest(&req, h.catalogue); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request", "fields": err})
+		return
+	}
+	if !authorizeUser(c, req.UserID) {
+		return
+	}
 
 	res, err := h.service.RequestMatch(c.Request.Context(), req)
+	if errors.Is(err, services.ErrShuttingDown) {
+		// Another replica can take the request
+		c.Header("Retry-After", "1")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -49,28 +79,115 @@ func (h *Handler) MatchStatus(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 		return
 	}
+	if !authorizeParticipant(c, res.UserIDs) {
+		return
+	}
 	c.JSON(http.StatusOK, res)
 }
 
+// MatchStatusByUser only reads the user's status. It does not count as a heartbeat, so a
+// forgotten tab or a monitoring script polling it cannot keep a user queued.
 func (h *Handler) MatchStatusByUser(c *gin.Context) {
 	userId := c.Param("userId")
+	if !authorizeUser(c, userId) {
+		return
+	}
 	status, details, err := h.service.CheckUserStatus(c.Request.Context(), userId)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	switch status {
-	case 2:
+	case services.UserStatusMatched:
 		c.JSON(http.StatusOK, gin.H{"status": 2, "matchId": details["matchId"]})
-	case 1:
+	case services.UserStatusWaiting:
 		c.JSON(http.StatusOK, gin.H{"status": 1, "queue": details["queue"], "position": details["position"]})
+	case services.UserStatusPending:
+		c.JSON(http.StatusOK, gin.H{"status": 4, "matchId": details["matchId"], "acceptBy": details["acceptBy"]})
+	case services.UserStatusTimedOut:
+		c.JSON(http.StatusOK, gin.H{"status": 3, "reason": "timeout", "queue": details["queue"]})
 	default:
 		c.JSON(http.StatusOK, gin.H{"status": 0})
 	}
 }
 
+func (h *Handler) Heartbeat(c *gin.Context) {
+	userId := c.Param("userId")
+	if !authorizeUser(c, userId) {
+		return
+	}
+	waiting, err := h.service.Heartbeat(c.Request.Context(), userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !waiting {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user is not waiting in a queue"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "alive"})
+}
+
+func (h *Handler) AcceptMatch(c *gin.Context) {
+	var req models.MatchActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.UserID = validation.NormalizeUserID(req.UserID)
+	if !authorizeUser(c, req.UserID) {
+		return
+	}
+	res, err := h.service.AcceptMatch(c.Request.Context(), c.Param("matchId"), req.UserID)
+	if err != nil {
+		c.JSON(handshakeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+func (h *Handler) DeclineMatch(c *gin.Context) {
+	var req models.MatchActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.UserID = validation.NormalizeUserID(req.UserID)
+	if !authorizeUser(c, req.UserID) {
+		return
+	}
+	matchId := c.Param("matchId")
+	if err := h.service.DeclineMatch(c.Request.Context(), matchId, req.UserID); err != nil {
+		c.JSON(handshakeErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "declined", "matchId": matchId})
+}
+
+func handshakeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrMatchNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrNotParticipant):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrMatchNotPending):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
 func (h *Handler) CancelMatch(c *gin.Context) {
 	id := c.Param("id")
+	if claims, _ := auth.FromContext(c.Request.Context()); claims == nil || !claims.IsAdmin {
+		match, err := h.service.CheckMatchStatus(c.Request.Context(), id)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if !authorizeParticipant(c, match.UserIDs) {
+			return
+		}
+	}
 	if err := h.service.CancelMatch(c.Request.Context(), id); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -80,6 +197,9 @@ func (h *Handler) CancelMatch(c *gin.Context) {
 
 func (h *Handler) CancelMatchByUser(c *gin.Context) {
 	userId := c.Param("userId")
+	if !authorizeUser(c, userId) {
+		return
+	}
 	state, res, err := h.service.CancelByUser(c.Request.Context(), userId)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -88,11 +208,50 @@ func (h *Handler) CancelMatchByUser(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": state, "matchId": res.MatchID})
 }
 
-func (h *Handler) GetQueue(c *gin.Context) {
-	users, err := h.service.GetQueueUsers(c.Request.Context())
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
+func (h *Handler) MatchHistory(c *gin.Context) {
+	userId := c.Param("userId")
+	if !authorizeUser(c, userId) {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultHistoryLimit)))
+	if err != nil || limit < 1 || limit > maxHistoryLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+		return
+	}
+
+	page, err := h.service.GetMatchHistory(c.Request.Context(), userId, limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-	c.JSON(http.StatusOK, users)
+	c.JSON(http.StatusOK, page)
+}
+
+// authorizeUser aborts with 403 unless the caller is userID or an admin
+func authorizeUser(c *gin.Context, userID string) bool {
+	if auth.CanActAs(c.Request.Context(), userID) {
+		return true
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "token does not match the requested user"})
+	return false
+}
+
+// authorizeParticipant aborts with 403 unless the caller is one of userIDs or an admin
+func authorizeParticipant(c *gin.Context, userIDs []string) bool {
+	claims, ok := auth.FromContext(c.Request.Context())
+	if ok && (claims.IsAdmin || claims.Unauthenticated || slices.Contains(userIDs, claims.UserID)) {
+		return true
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "not a participant in this match"})
+	return false
 }
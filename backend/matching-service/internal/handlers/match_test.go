@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"matching-service/internal/auth"
+	"matching-service/internal/repository"
+	"matching-service/internal/services"
+	"matching-service/internal/validation"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "test-secret"
+
+func newTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	router, _ := newTestRouterWithService(t)
+	return router
+}
+
+func newTestRouterWithService(t *testing.T) (*gin.Engine, *services.MatchingService) {
+	t.Helper()
+	return newTestRouterWithOptions(t, services.Options{})
+}
+
+func newTestRouterWithOptions(t *testing.T, opts services.Options) (*gin.Engine, *services.MatchingService) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	history := repository.NewMemoryMatchHistoryRepository()
+	service := services.NewMatchingService(
+		repository.NewMemoryMatchStore(),
+		repository.NewMemoryUserLookup(),
+		repository.NewMemoryQuestionLookup(),
+		history,
+		opts,
+	)
+	verifier, err := auth.NewVerifier(auth.Options{Secret: testSecret, AdminUserIDs: []string{"admin"}})
+	if err != nil {
+		t.Fatalf("creating verifier: %v", err)
+	}
+	router := gin.New()
+	RegisterRoutes(router, service, verifier, validation.NewCatalogue(nil), nil, testOrigins)
+	RegisterAdminRoutes(router, service, verifier)
+	return router, service
+}
+
+func tokenFor(t *testing.T, userID string) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"id":  userID,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(testSecret))
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+	return token
+}
+
+func TestRoutesRequireMatchingUser(t *testing.T) {
+	router := newTestRouter(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		caller     string
+		wantStatus int
+	}{
+		{name: "no token", method: http.MethodGet, target: "/match/status/by-user/u1", wantStatus: http.StatusUnauthorized},
+		{name: "own status", method: http.MethodGet, target: "/match/status/by-user/u1", caller: "u1", wantStatus: http.StatusOK},
+		{name: "someone else's status", method: http.MethodGet, target: "/match/status/by-user/u1", caller: "u2", wantStatus: http.StatusForbidden},
+		{name: "admin reads any status", method: http.MethodGet, target: "/match/status/by-user/u1", caller: "admin", wantStatus: http.StatusOK},
+		{name: "cancel someone else", method: http.MethodDelete, target: "/match/cancel/by-user/u1", caller: "u2", wantStatus: http.StatusForbidden},
+		{name: "request as someone else", method: http.MethodPost, target: "/match/request", body: `{"userId":"u1","topics":["array"],"difficulty":"easy"}`, caller: "u2", wantStatus: http.StatusForbidden},
+		{name: "request as self", method: http.MethodPost, target: "/match/request", body: `{"userId":"u1","topics":["array"],"difficulty":"easy"}`, caller: "u1", wantStatus: http.StatusOK},
+		{name: "invalid request", method: http.MethodPost, target: "/match/request", body: `{"userId":"u1","topics":[],"difficulty":"extreme"}`, caller: "u1", wantStatus: http.StatusBadRequest},
+		{name: "accept as someone else", method: http.MethodPost, target: "/match/m1/accept", body: `{"userId":"u1"}`, caller: "u2", wantStatus: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.caller != "" {
+				req.Header.Set("Authorization", "Bearer "+tokenFor(t, tt.caller))
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRequestMatchWhileShuttingDown(t *testing.T) {
+	router, service := newTestRouterWithService(t)
+	service.StopAccepting()
+
+	req := httptest.NewRequest(http.MethodPost, "/match/request", strings.NewReader(`{"userId":"u1","topics":["array"],"difficulty":"easy"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+tokenFor(t, "u1"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("status = %d, Retry-After = %q; want 503 with Retry-After", w.Code, w.Header().Get("Retry-After"))
+	}
+}
+
+func TestOnlyExplicitHeartbeatsKeepUsersQueued(t *testing.T) {
+	send := func(router *gin.Engine, method, target, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+tokenFor(t, "u1"))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, heartbeats := range []bool{false, true} {
+		router, service := newTestRouterWithOptions(t, services.Options{HeartbeatGrace: 100 * time.Millisecond})
+		if w := send(router, http.MethodPost, "/match/request", `{"userId":"u1","topics":["array"],"difficulty":"easy"}`); w.Code != http.StatusOK {
+			t.Fatalf("request status = %d (%s)", w.Code, w.Body.String())
+		}
+		for range 8 {
+			time.Sleep(25 * time.Millisecond)
+			send(router, http.MethodGet, "/match/status/by-user/u1", "")
+			if heartbeats {
+				send(router, http.MethodPost, "/match/heartbeat/u1", "")
+			}
+		}
+		if _, err := service.RunMatchmakingPass(context.Background()); err != nil {
+			t.Fatalf("RunMatchmakingPass: %v", err)
+		}
+
+		want := `"status":3`
+		if heartbeats {
+			want = `"status":1`
+		}
+		if w := send(router, http.MethodGet, "/match/status/by-user/u1", ""); !strings.Contains(w.Body.String(), want) {
+			t.Fatalf("heartbeats=%v: status = %s, want %s", heartbeats, w.Body.String(), want)
+		}
+	}
+}
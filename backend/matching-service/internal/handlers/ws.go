@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"matching-service/internal/cors"
+	"matching-service/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// statusRefreshInterval is how often the user's state is re-read in case a published
+	// event was missed; queue changes are otherwise pushed as they happen
+	statusRefreshInterval = 30 * time.Second
+	// heartbeatInterval keeps a connected user's liveness fresh while they wait
+	heartbeatInterval = 10 * time.Second
+	pingInterval      = 30 * time.Second
+	writeTimeout      = 10 * time.Second
+)
+
+// newUpgrader accepts connections from the origins allowed by the CORS policy. Clients
+// outside a browser send no Origin and are let through, as they are for CORS. A nil policy
+// only accepts same-origin connections.
+func newUpgrader(origins *cors.Policy) *websocket.Upgrader {
+	upgrader := &websocket.Upgrader{}
+	if origins != nil {
+		upgrader.CheckOrigin = func(r *http.Request) bool {
+			origin := r.Header.Get("Origin")
+			return origin == "" || origins.Allowed(origin)
+		}
+	}
+	return upgrader
+}
+
+// MatchEvents upgrades to a WebSocket and pushes the user's match events until a
+// matched, cancelled or timeout event is delivered or the client disconnects.
+func (h *Handler) MatchEvents(c *gin.Context) {
+	userId := c.Param("userId")
+	if !authorizeUser(c, userId) {
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade has already written an HTTP error response
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	// Subscribe before reading the current state so no event is missed in between
+	events, unsubscribe, err := h.service.SubscribeUserEvents(ctx, userId)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to subscribe to events", "user_id", userId, "error", err)
+		_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, "subscription failed"), time.Now().Add(writeTimeout))
+		return
+	}
+	defer unsubscribe()
+
+	// Read pump: the client sends nothing, but reading is needed to notice disconnects
+	go func() {
+		defer cancel()
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	var last *models.MatchEvent
+	// send writes the event if it differs from the last one and reports whether the stream should end
+	send := func(event *models.MatchEvent) bool {
+		if event == nil || sameEvent(last, event) {
+			return false
+		}
+		_ = conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if err := conn.WriteJSON(event); err != nil {
+			cancel()
+			return true
+		}
+		last = event
+		return isFinalEvent(event)
+	}
+
+	current, err := h.service.CurrentUserEvent(ctx, userId)
+	if err == nil && send(current) {
+		closeNormally(conn)
+		return
+	}
+
+	refresh := time.NewTicker(statusRefreshInterval)
+	defer refresh.Stop()
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if h.service.ShuttingDown() {
+				// Tell the client to reconnect, which reaches another replica
+				_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "server shutting down"), time.Now().Add(writeTimeout))
+			}
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if send(&event) {
+				closeNormally(conn)
+				return
+			}
+		case <-heartbeat.C:
+			// An open connection proves the user is still present
+			_, _ = h.service.Heartbeat(ctx, userId)
+		case <-refresh.C:
+			current, err := h.service.CurrentUserEvent(ctx, userId)
+			if err == nil && send(current) {
+				closeNormally(conn)
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(writeTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func isFinalEvent(event *models.MatchEvent) bool {
+	switch event.Type {
+	case models.EventMatched, models.EventCancelled, models.EventTimeout:
+		return true
+	}
+	return false
+}
+
+func sameEvent(a, b *models.MatchEvent) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Type != b.Type || a.MatchID != b.MatchID || a.Queue != b.Queue {
+		return false
+	}
+	if a.Position == nil || b.Position == nil {
+		return a.Position == b.Position
+	}
+	return *a.Position == *b.Position
+}
+
+func closeNormally(conn *websocket.Conn) {
+	_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(writeTimeout))
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"matching-service/internal/cors"
+	"matching-service/internal/models"
+
+	"github.com/gorilla/websocket"
+)
+
+var testOrigins, _ = cors.NewPolicy([]string{"http://localhost:3000"})
+
+// dialEvents opens the user's event stream from origin, authenticated as the user
+func dialEvents(t *testing.T, server *httptest.Server, userID, origin string) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/match/ws/" + userID
+	header := http.Header{"Authorization": {"Bearer " + tokenFor(t, userID)}}
+	if origin != "" {
+		header.Set("Origin", origin)
+	}
+	conn, res, err := websocket.DefaultDialer.Dial(url, header)
+	if conn != nil {
+		t.Cleanup(func() { conn.Close() })
+	}
+	return conn, res, err
+}
+
+func TestMatchEventsClosesAfterFinalEvent(t *testing.T) {
+	router, service := newTestRouterWithService(t)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+	ctx := context.Background()
+
+	if _, err := service.RequestMatch(ctx, models.MatchRequest{Topics: []string{"array"}, Difficulty: "easy", UserID: "u1"}); err != nil {
+		t.Fatalf("RequestMatch returned error: %v", err)
+	}
+	conn, _, err := dialEvents(t, server, "u1", "http://localhost:3000")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+
+	var event models.MatchEvent
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatalf("reading current state: %v", err)
+	}
+	if event.Type != models.EventWaiting || event.Position == nil || *event.Position != 0 {
+		t.Fatalf("first event = %+v, want waiting at position 0", event)
+	}
+
+	if _, _, err := service.CancelByUser(ctx, "u1"); err != nil {
+		t.Fatalf("CancelByUser returned error: %v", err)
+	}
+	if err := conn.ReadJSON(&event); err != nil {
+		t.Fatalf("reading cancellation: %v", err)
+	}
+	if event.Type != models.EventCancelled {
+		t.Fatalf("event = %+v, want cancelled", event)
+	}
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.CloseNormalClosure {
+		t.Fatalf("read after the final event = %v, want a normal close", err)
+	}
+}
+
+func TestMatchEventsChecksOrigin(t *testing.T) {
+	router := newTestRouter(t)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name   string
+		origin string
+		wantOK bool
+	}{
+		{name: "allowed origin", origin: "http://localhost:3000", wantOK: true},
+		{name: "no origin", wantOK: true},
+		{name: "disallowed origin", origin: "https://evil.example"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, res, err := dialEvents(t, server, "u1", tt.origin)
+			if tt.wantOK {
+				if err != nil {
+					t.Fatalf("dial: %v", err)
+				}
+				return
+			}
+			if err == nil || res == nil || res.StatusCode != http.StatusForbidden {
+				t.Fatalf("dial = %v, want 403", err)
+			}
+		})
+	}
+}
+
+func TestSameEventAndIsFinalEvent(t *testing.T) {
+	position := func(p int64) *int64 { return &p }
+	tests := []struct {
+		name  string
+		a, b  *models.MatchEvent
+		same  bool
+		final bool
+	}{
+		{name: "nothing sent yet", b: &models.MatchEvent{Type: models.EventWaiting}},
+		{name: "same position", a: &models.MatchEvent{Type: models.EventWaiting, Position: position(1)}, b: &models.MatchEvent{Type: models.EventWaiting, Position: position(1)}, same: true},
+		{name: "moved up", a: &models.MatchEvent{Type: models.EventWaiting, Position: position(1)}, b: &models.MatchEvent{Type: models.EventWaiting, Position: position(0)}},
+		{name: "position now known", a: &models.MatchEvent{Type: models.EventWaiting}, b: &models.MatchEvent{Type: models.EventWaiting, Position: position(0)}},
+		{name: "pending then matched", a: &models.MatchEvent{Type: models.EventPendingAccept, MatchID: "m"}, b: &models.MatchEvent{Type: models.EventMatched, MatchID: "m"}, final: true},
+		{name: "cancelled", b: &models.MatchEvent{Type: models.EventCancelled}, final: true},
+		{name: "timed out", b: &models.MatchEvent{Type: models.EventTimeout, Queue: "queue:easy:array"}, final: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameEvent(tt.a, tt.b); got != tt.same {
+				t.Errorf("sameEvent = %v, want %v", got, tt.same)
+			}
+			if got := isFinalEvent(tt.b); got != tt.final {
+				t.Errorf("isFinalEvent = %v, want %v", got, tt.final)
+			}
+		})
+	}
+}
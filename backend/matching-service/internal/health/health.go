@@ -0,0 +1,91 @@
+package health
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Dependency status values
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Readiness status values
+const (
+	StatusReady    = "ready"
+	StatusNotReady = "not_ready"
+)
+
+// Check probes one dependency. A replica is only ready while every required check passes;
+// optional checks are reported but never fail readiness.
+type Check struct {
+	Name     string
+	Required bool
+	Probe    func(ctx context.Context) error
+}
+
+// Result is the outcome of a single check
+type Result struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	Required  bool    `json:"required"`
+	LatencyMs float64 `json:"latencyMs"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the readiness of the replica and the result of every check
+type Report struct {
+	Status       string   `json:"status"`
+	Dependencies []Result `json:"dependencies"`
+}
+
+// Ready reports whether the replica should receive traffic
+func (r Report) Ready() bool {
+	return r.Status == StatusReady
+}
+
+// Run probes every dependency concurrently, each bounded by timeout, and returns the
+// results in the order the checks were given
+func Run(ctx context.Context, checks []Check, timeout time.Duration) Report {
+	results := make([]Result, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = run(ctx, check, timeout)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusReady, Dependencies: results}
+	for _, result := range results {
+		if result.Required && result.Status != StatusUp {
+			report.Status = StatusNotReady
+		}
+	}
+	return report
+}
+
+func run(ctx context.Context, check Check, timeout time.Duration) Result {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	err := check.Probe(ctx)
+	result := Result{
+		Name:      check.Name,
+		Status:    StatusUp,
+		Required:  check.Required,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,73 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func probe(err error) func(context.Context) error {
+	return func(context.Context) error { return err }
+}
+
+func TestRunFailsOnlyOnRequiredDependencies(t *testing.T) {
+	down := errors.New("connection refused")
+
+	tests := []struct {
+		name      string
+		checks    []Check
+		wantReady bool
+	}{
+		{
+			name:      "all up",
+			checks:    []Check{{Name: "redis", Required: true, Probe: probe(nil)}, {Name: "user-service", Probe: probe(nil)}},
+			wantReady: true,
+		},
+		{
+			name:      "optional dependency down",
+			checks:    []Check{{Name: "redis", Required: true, Probe: probe(nil)}, {Name: "user-service", Probe: probe(down)}},
+			wantReady: true,
+		},
+		{
+			name:      "required dependency down",
+			checks:    []Check{{Name: "redis", Required: true, Probe: probe(down)}, {Name: "user-service", Probe: probe(nil)}},
+			wantReady: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Run(context.Background(), tt.checks, time.Second)
+			if report.Ready() != tt.wantReady {
+				t.Fatalf("ready = %v, want %v (%+v)", report.Ready(), tt.wantReady, report)
+			}
+			if len(report.Dependencies) != len(tt.checks) {
+				t.Fatalf("got %d results, want %d", len(report.Dependencies), len(tt.checks))
+			}
+			for i, result := range report.Dependencies {
+				if result.Name != tt.checks[i].Name {
+					t.Fatalf("result %d is %s, want %s", i, result.Name, tt.checks[i].Name)
+				}
+				if (result.Status == StatusDown) != (result.Error != "") {
+					t.Fatalf("result %+v has mismatched status and error", result)
+				}
+			}
+		})
+	}
+}
+
+func TestRunBoundsSlowChecks(t *testing.T) {
+	slow := Check{Name: "redis", Required: true, Probe: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+
+	start := time.Now()
+	report := Run(context.Background(), []Check{slow}, 20*time.Millisecond)
+	if report.Ready() {
+		t.Fatal("a check that timed out should fail readiness")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("Run took %v, want it bounded by the 20ms timeout", elapsed)
+	}
+}
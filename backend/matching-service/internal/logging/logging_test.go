@@ -0,0 +1,77 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMiddlewareAssignsOrAcceptsRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/ping", func(c *gin.Context) {
+		c.String(http.StatusOK, RequestID(c.Request.Context()))
+	})
+
+	tests := []struct {
+		name     string
+		incoming string
+		wantSame bool
+	}{
+		{name: "no header", incoming: ""},
+		{name: "caller's ID", incoming: "abc-123", wantSame: true},
+		{name: "ID with spaces", incoming: "abc 123"},
+		{name: "oversized ID", incoming: strings.Repeat("a", maxRequestIDLength+1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+			if tt.incoming != "" {
+				req.Header.Set(RequestIDHeader, tt.incoming)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			id := w.Header().Get(RequestIDHeader)
+			if id == "" || id != w.Body.String() {
+				t.Fatalf("response header %q and context ID %q should match and be set", id, w.Body.String())
+			}
+			if (id == tt.incoming) != tt.wantSame {
+				t.Fatalf("request ID = %q, incoming %q, want reused = %v", id, tt.incoming, tt.wantSame)
+			}
+		})
+	}
+}
+
+func TestLoggerAddsRequestIDFromContext(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, slog.LevelInfo)
+
+	logger.InfoContext(WithRequestID(context.Background(), "req-1"), "matched", "match_id", "m1")
+	logger.Debug("hidden below the level")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("expected one JSON record, got %q: %v", buf.String(), err)
+	}
+	if record["request_id"] != "req-1" || record["match_id"] != "m1" || record["msg"] != "matched" {
+		t.Fatalf("record = %v", record)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	if level, err := ParseLevel("WARN"); err != nil || level != slog.LevelWarn {
+		t.Fatalf("ParseLevel(WARN) = %v, %v", level, err)
+	}
+	if level, err := ParseLevel("loud"); err == nil || level != slog.LevelInfo {
+		t.Fatalf("ParseLevel(loud) = %v, %v; want info and an error", level, err)
+	}
+}
@@ -0,0 +1,205 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"matching-service/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Match outcomes counted by matching_match_outcomes_total
+const (
+	OutcomeMatched            = "matched"
+	OutcomeNoSuitableQuestion = "no_suitable_question"
+	OutcomeCancelled          = "cancelled"
+	OutcomeTimeout            = "timeout"
+)
+
+const namespace = "matching"
+
+// queueDepthTimeout bounds the queue scan made on every scrape
+const queueDepthTimeout = 2 * time.Second
+
+// Metrics holds the service's Prometheus collectors on their own registry. The recording
+// methods are safe to call on a nil *Metrics, so components built without metrics record nothing.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	waitTime        prometheus.Histogram
+	matchOutcomes   *prometheus.CounterVec
+	outboundLatency *prometheus.HistogramVec
+	questionCache   *prometheus.CounterVec
+	rateLimited     *prometheus.CounterVec
+	httpRequests    *prometheus.CounterVec
+	httpLatency     *prometheus.HistogramVec
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		waitTime: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "queue_wait_seconds",
+			Help:      "Time users waited in a queue before being matched.",
+			Buckets:   []float64{1, 5, 10, 30, 60, 120, 300, 600, 1200},
+		}),
+		matchOutcomes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "match_outcomes_total",
+			Help:      "Matching outcomes: matches formed, pairs without a suitable question, cancellations and timeouts.",
+		}, []string{"outcome"}),
+		outboundLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "outbound_request_duration_seconds",
+			Help:      "Latency of each attempt to call user-service or question-service.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"service", "status"}),
+		questionCache: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "question_cache_lookups_total",
+			Help:      "Question candidate lookups by whether they were served from the cache.",
+		}, []string{"result"}),
+		rateLimited: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rate_limited_requests_total",
+			Help:      "Requests refused with 429 by the rate limiter, by route.",
+		}, []string{"route"}),
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests handled, by route and status code.",
+		}, []string{"method", "route", "status"}),
+		httpLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Latency of HTTP requests by route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+	}
+	m.registry.MustRegister(
+		m.waitTime, m.matchOutcomes, m.outboundLatency, m.questionCache, m.rateLimited, m.httpRequests, m.httpLatency,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// WatchQueues reports the depth of every queue, read from source on each scrape
+func (m *Metrics) WatchQueues(source func(ctx context.Context) ([]models.QueueInfo, error)) {
+	if m == nil {
+		return
+	}
+	m.registry.MustRegister(&queueDepthCollector{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "queue_depth"),
+			"Users waiting in each queue.",
+			[]string{"queue", "difficulty", "topics"}, nil,
+		),
+		source: source,
+	})
+}
+
+// ObserveMatchOutcome counts one matching outcome
+func (m *Metrics) ObserveMatchOutcome(outcome string) {
+	if m == nil {
+		return
+	}
+	m.matchOutcomes.WithLabelValues(outcome).Inc()
+}
+
+// ObserveQueueWait records how long a user waited before being matched
+func (m *Metrics) ObserveQueueWait(wait time.Duration) {
+	if m == nil {
+		return
+	}
+	m.waitTime.Observe(wait.Seconds())
+}
+
+// ObserveOutbound records one attempt to call another service. status is the response's
+// status code, or 0 when no response arrived.
+func (m *Metrics) ObserveOutbound(service string, status int, elapsed time.Duration) {
+	if m == nil {
+		return
+	}
+	label := "error"
+	if status > 0 {
+		label = strconv.Itoa(status)
+	}
+	m.outboundLatency.WithLabelValues(service, label).Observe(elapsed.Seconds())
+}
+
+// ObserveQuestionCache counts a question cache lookup
+func (m *Metrics) ObserveQuestionCache(hit bool) {
+	if m == nil {
+		return
+	}
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.questionCache.WithLabelValues(result).Inc()
+}
+
+// ObserveRateLimited counts a request refused by the rate limiter
+func (m *Metrics) ObserveRateLimited(route string) {
+	if m == nil {
+		return
+	}
+	m.rateLimited.WithLabelValues(route).Inc()
+}
+
+// Middleware records every request under its route pattern, so path parameters such as
+// user IDs do not create a series each
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if m == nil {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		m.httpRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		m.httpLatency.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// queueDepthCollector reads the queue sizes when scraped, so depth is current even when
+// the background matchmaker is disabled
+type queueDepthCollector struct {
+	desc   *prometheus.Desc
+	source func(ctx context.Context) ([]models.QueueInfo, error)
+}
+
+func (c *queueDepthCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *queueDepthCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), queueDepthTimeout)
+	defer cancel()
+	queues, err := c.source(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to read queue depths for metrics", "error", err)
+		return
+	}
+	for _, q := range queues {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(q.Size), q.Key, q.Difficulty, q.Topics)
+	}
+}
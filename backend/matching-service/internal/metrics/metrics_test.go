@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"matching-service/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("scrape status = %d", w.Code)
+	}
+	return w.Body.String()
+}
+
+func assertContains(t *testing.T, body string, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if !strings.Contains(body, line) {
+			t.Errorf("metrics missing %q", line)
+		}
+	}
+}
+
+func TestMetricsRecordsObservations(t *testing.T) {
+	m := New()
+	m.ObserveMatchOutcome(OutcomeMatched)
+	m.ObserveMatchOutcome(OutcomeMatched)
+	m.ObserveMatchOutcome(OutcomeTimeout)
+	m.ObserveQueueWait(45 * time.Second)
+	m.ObserveOutbound("question-service", 200, 30*time.Millisecond)
+	m.ObserveOutbound("user-service", 0, time.Second)
+	m.ObserveQuestionCache(true)
+	m.ObserveRateLimited("match_request")
+	m.WatchQueues(func(context.Context) ([]models.QueueInfo, error) {
+		return []models.QueueInfo{{Key: "queue:easy:array", Difficulty: "easy", Topics: "array", Size: 3}}, nil
+	})
+
+	assertContains(t, scrape(t, m),
+		`matching_match_outcomes_total{outcome="matched"} 2`,
+		`matching_match_outcomes_total{outcome="timeout"} 1`,
+		`matching_queue_wait_seconds_bucket{le="60"} 1`,
+		`matching_outbound_request_duration_seconds_count{service="question-service",status="200"} 1`,
+		`matching_outbound_request_duration_seconds_count{service="user-service",status="error"} 1`,
+		`matching_question_cache_lookups_total{result="hit"} 1`,
+		`matching_rate_limited_requests_total{route="match_request"} 1`,
+		`matching_queue_depth{difficulty="easy",queue="queue:easy:array",topics="array"} 3`,
+	)
+}
+
+func TestMiddlewareLabelsRequestsByRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := New()
+	router := gin.New()
+	router.Use(m.Middleware())
+	router.GET("/match/status/by-user/:userId", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, target := range []string{"/match/status/by-user/u1", "/match/status/by-user/u2", "/nowhere"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	assertContains(t, scrape(t, m),
+		`matching_http_requests_total{method="GET",route="/match/status/by-user/:userId",status="200"} 2`,
+		`matching_http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+	)
+}
+
+func TestNilMetricsRecordsNothing(t *testing.T) {
+	var m *Metrics
+	m.ObserveMatchOutcome(OutcomeMatched)
+	m.ObserveQueueWait(time.Second)
+	m.ObserveOutbound("user-service", 200, time.Second)
+	m.ObserveQuestionCache(false)
+	m.ObserveRateLimited("match")
+	m.WatchQueues(nil)
+
+	router := gin.New()
+	router.Use(m.Middleware())
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// QueueSummary lists every non-empty queue and how many users are waiting overall
+type QueueSummary struct {
+	Queues       []QueueInfo `json:"queues"`
+	TotalWaiting int64       `json:"totalWaiting"`
+}
+
+// ForcePairRequest names the two queued users an admin wants matched
+type ForcePairRequest struct {
+	UserIDs []string `json:"userIds" binding:"required,len=2,dive,required"`
+}
+
+// DrainQueueRequest names the queue an admin wants emptied
+type DrainQueueRequest struct {
+	Queue string `json:"queue" binding:"required"`
+}
+
+// DrainQueueResponse lists the users removed from a drained queue
+type DrainQueueResponse struct {
+	Queue   string   `json:"queue"`
+	Removed []string `json:"removed"`
+}
+
+// Admin actions recorded in the audit log
+const (
+	AuditQueueSummary = "queue_summary"
+	AuditListQueue    = "list_queue_users"
+	AuditRemoveUser   = "remove_user"
+	AuditForcePair    = "force_pair"
+	AuditDrainQueue   = "drain_queue"
+	AuditCancelMatch  = "cancel_match"
+)
+
+// AuditEntry records one admin action and whether it succeeded
+type AuditEntry struct {
+	Time    time.Time `json:"time"`
+	Actor   string    `json:"actor"`
+	Action  string    `json:"action"`
+	UserIDs []string  `json:"userIds,omitempty"`
+	Queue   string    `json:"queue,omitempty"`
+	MatchID string    `json:"matchId,omitempty"`
+	Success bool      `json:"success"`
+	Error   string    `json:"error,omitempty"`
+}
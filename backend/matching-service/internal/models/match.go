@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 type MatchRequest struct {
 	Topics     []string `json:"topics"`
 	Difficulty string   `json:"difficulty"`
@@ -7,10 +9,21 @@ type MatchRequest struct {
 }
 
 type MatchResponse struct {
-	MatchID    string   `json:"matchId,omitempty"`
-	UserIDs    []string `json:"userIds,omitempty"`
-	QuestionID string   `json:"questionId,omitempty"`
-	Status     string   `json:"status"`
+	MatchID       string   `json:"matchId,omitempty"`
+	UserIDs       []string `json:"userIds,omitempty"`
+	Difficulty    string   `json:"difficulty,omitempty"`
+	Topics        []string `json:"topics,omitempty"`
+	QuestionID    string   `json:"questionId,omitempty"`
+	QuestionTitle string   `json:"questionTitle,omitempty"`
+	QuestionSlug  string   `json:"questionSlug,omitempty"`
+	// TopicCoverage lists the requested topics the chosen question is tagged with
+	TopicCoverage []string   `json:"topicCoverage,omitempty"`
+	Status        string     `json:"status"`
+	CreatedAt     *time.Time `json:"createdAt,omitempty"`
+	ExpiresAt     *time.Time `json:"expiresAt,omitempty"`
+	AcceptBy      int64      `json:"acceptBy,omitempty"`
+	// Relaxed lists the criteria ("topics", "difficulty") that were widened to form the match
+	Relaxed []string `json:"relaxed,omitempty"`
 }
 
 type QueueInfo struct {
@@ -25,3 +38,91 @@ type QueueUser struct {
 	Topics     []string `json:"topics"`
 	Difficulty string   `json:"difficulty"`
 }
+
+// Match record statuses
+const (
+	MatchStatusPendingAccept = "pending_accept"
+	MatchStatusMatched       = "matched"
+	MatchStatusDeclined      = "declined"
+	MatchStatusExpired       = "expired"
+)
+
+// MatchActionRequest identifies the user accepting or declining a match
+type MatchActionRequest struct {
+	UserID string `json:"userId" binding:"required"`
+}
+
+// Match event types pushed to connected clients
+const (
+	EventWaiting       = "waiting"
+	EventPendingAccept = "pending_accept"
+	EventMatched       = "matched"
+	EventCancelled     = "cancelled"
+	EventTimeout       = "timeout"
+)
+
+// MatchEvent describes a change in a user's match state
+type MatchEvent struct {
+	Type       string `json:"type"`
+	MatchID    string `json:"matchId,omitempty"`
+	PartnerID  string `json:"partnerId,omitempty"`
+	QuestionID string `json:"questionId,omitempty"`
+	Queue      string `json:"queue,omitempty"`
+	Position   *int64 `json:"position,omitempty"`
+	AcceptBy   int64  `json:"acceptBy,omitempty"`
+	// Reason explains events caused by the partner or an admin, e.g. "partner_cancelled"
+	Reason string `json:"reason,omitempty"`
+}
+
+// Reasons attached to events the user did not cause, e.g. ones caused by the other user or an admin
+const (
+	ReasonPartnerCancelled   = "partner_cancelled"
+	ReasonPartnerDeclined    = "partner_declined"
+	ReasonPartnerTimeout     = "partner_timeout"
+	ReasonRemovedByAdmin     = "removed_by_admin"
+	ReasonNoSuitableQuestion = "no_suitable_question"
+)
+
+// Match history outcomes. A completed match is recorded as matched, and an accept request
+// left unanswered as expired.
+const (
+	OutcomeMatched   = "matched"
+	OutcomeCancelled = "cancelled"
+	OutcomeDeclined  = "declined"
+	OutcomeExpired   = "expired"
+)
+
+// MatchHistoryRecord is a finished match as persisted in the history store
+type MatchHistoryRecord struct {
+	MatchID       string    `json:"matchId"`
+	UserIDs       []string  `json:"userIds"`
+	Difficulty    string    `json:"difficulty"`
+	Topics        []string  `json:"topics"`
+	QuestionID    string    `json:"questionId"`
+	QuestionTitle string    `json:"questionTitle,omitempty"`
+	QuestionSlug  string    `json:"questionSlug,omitempty"`
+	Outcome       string    `json:"outcome"`
+	MatchedAt     time.Time `json:"matchedAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// MatchHistoryEntry is a history record from one user's point of view
+type MatchHistoryEntry struct {
+	MatchID       string    `json:"matchId"`
+	PartnerID     string    `json:"partnerId"`
+	QuestionID    string    `json:"questionId"`
+	QuestionTitle string    `json:"questionTitle,omitempty"`
+	QuestionSlug  string    `json:"questionSlug,omitempty"`
+	Topics        []string  `json:"topics"`
+	Difficulty    string    `json:"difficulty"`
+	Outcome       string    `json:"outcome"`
+	MatchedAt     time.Time `json:"matchedAt"`
+}
+
+// MatchHistoryPage is a page of a user's match history
+type MatchHistoryPage struct {
+	Items  []MatchHistoryEntry `json:"items"`
+	Total  int                 `json:"total"`
+	Limit  int                 `json:"limit"`
+	Offset int                 `json:"offset"`
+}
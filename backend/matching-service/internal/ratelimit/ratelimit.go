@@ -0,0 +1,106 @@
+package ratelimit
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"matching-service/internal/auth"
+	"matching-service/internal/constants"
+	"matching-service/internal/metrics"
+	"matching-service/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Routes with their own limits
+const (
+	// RouteMatch covers every /match route
+	RouteMatch = "match"
+	// RouteMatchRequest covers POST /match/request, which re-queues the user and may call question-service
+	RouteMatchRequest = "match_request"
+)
+
+// Rule limits one route to a number of requests a minute for each authenticated user and
+// for each client IP. Up to a minute's worth may arrive at once; zero disables a limit.
+type Rule struct {
+	PerUser int
+	PerIP   int
+}
+
+// Store takes tokens from buckets; repository.RateLimitRepository keeps them in Redis
+type Store interface {
+	Take(ctx context.Context, buckets []repository.Bucket, now time.Time) (time.Duration, error)
+}
+
+// Options configures a Limiter
+type Options struct {
+	// Rules limits routes by name; routes without a rule are not limited
+	Rules   map[string]Rule
+	Metrics *metrics.Metrics
+}
+
+// Limiter throttles routes with token buckets shared by every replica
+type Limiter struct {
+	store   Store
+	rules   map[string]Rule
+	metrics *metrics.Metrics
+	now     func() time.Time
+}
+
+func New(store Store, opts Options) *Limiter {
+	return &Limiter{store: store, rules: opts.Rules, metrics: opts.Metrics, now: time.Now}
+}
+
+// Middleware throttles requests to route, answering 429 with Retry-After once the caller's
+// user or IP has used up its limit. It must run after auth.Middleware so requests can be
+// keyed by user. If the store fails the request is let through, so an unavailable Redis
+// does not take the API down with it. A nil Limiter limits nothing.
+func (l *Limiter) Middleware(route string) gin.HandlerFunc {
+	if l == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	rule := l.rules[route]
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		var buckets []repository.Bucket
+		if claims, ok := auth.FromContext(ctx); ok && claims.UserID != "" && rule.PerUser > 0 {
+			buckets = append(buckets, bucket(route, "user", claims.UserID, rule.PerUser))
+		}
+		if rule.PerIP > 0 {
+			buckets = append(buckets, bucket(route, "ip", c.ClientIP(), rule.PerIP))
+		}
+		if len(buckets) == 0 {
+			c.Next()
+			return
+		}
+
+		retryAfter, err := l.store.Take(ctx, buckets, l.now())
+		if err != nil {
+			slog.WarnContext(ctx, "rate limiter unavailable; allowing request", "route", route, "error", err)
+			c.Next()
+			return
+		}
+		if retryAfter > 0 {
+			l.metrics.ObserveRateLimited(route)
+			slog.InfoContext(ctx, "rate limited request", "route", route, "client_ip", c.ClientIP(), "retry_after_ms", retryAfter.Milliseconds())
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "too many requests"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// bucket holds perMinute tokens, regaining them evenly over a minute
+func bucket(route, kind, id string, perMinute int) repository.Bucket {
+	return repository.Bucket{
+		Key:      strings.Join([]string{constants.RateLimitKeyPrefix, route, kind, id}, constants.QueueKeyDelimiter),
+		Capacity: perMinute,
+		Refill:   time.Minute / time.Duration(perMinute),
+	}
+}
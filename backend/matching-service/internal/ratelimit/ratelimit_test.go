@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"matching-service/internal/auth"
+	"matching-service/internal/repository"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+func newTestLimiter(t *testing.T, rules map[string]Rule) *Limiter {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	limiter := New(repository.NewRateLimitRepository(client), Options{Rules: rules})
+	now := time.Unix(1000, 0)
+	limiter.now = func() time.Time { return now }
+	return limiter
+}
+
+// newTestRouter serves POST /match/request limited as route, trusting the caller named in
+// the X-User header as auth.Middleware would
+func newTestRouter(limiter *Limiter, route string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if user := c.GetHeader("X-User"); user != "" {
+			c.Request = c.Request.WithContext(auth.WithClaims(c.Request.Context(), &auth.Claims{UserID: user}))
+		}
+	})
+	router.POST("/match/request", limiter.Middleware(route), func(c *gin.Context) { c.Status(http.StatusOK) })
+	return router
+}
+
+func send(router *gin.Engine, user, ip string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/match/request", nil)
+	req.RemoteAddr = ip + ":1234"
+	if user != "" {
+		req.Header.Set("X-User", user)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestMiddlewareLimitsEachUser(t *testing.T) {
+	router := newTestRouter(newTestLimiter(t, map[string]Rule{RouteMatchRequest: {PerUser: 2}}), RouteMatchRequest)
+
+	for i := 0; i < 2; i++ {
+		if w := send(router, "u1", "10.0.0.1"); w.Code != http.StatusOK {
+			t.Fatalf("request %d status = %d, want 200", i, w.Code)
+		}
+	}
+	w := send(router, "u1", "10.0.0.2")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("third request status = %d, want 429 from any IP", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "30" {
+		t.Fatalf("Retry-After = %q, want 30 for 2 requests a minute", got)
+	}
+	if w := send(router, "u2", "10.0.0.1"); w.Code != http.StatusOK {
+		t.Fatalf("another user status = %d, want 200", w.Code)
+	}
+}
+
+func TestMiddlewareLimitsEachIP(t *testing.T) {
+	router := newTestRouter(newTestLimiter(t, map[string]Rule{RouteMatchRequest: {PerUser: 10, PerIP: 1}}), RouteMatchRequest)
+
+	if w := send(router, "u1", "10.0.0.1"); w.Code != http.StatusOK {
+		t.Fatalf("first request status = %d, want 200", w.Code)
+	}
+	w := send(router, "u2", "10.0.0.1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("second user from the same IP status = %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "60" {
+		t.Fatalf("Retry-After = %q, want 60", got)
+	}
+	if w := send(router, "u2", "10.0.0.2"); w.Code != http.StatusOK {
+		t.Fatalf("second user from another IP status = %d, want 200: the refused request must not use up their bucket", w.Code)
+	}
+}
+
+func TestMiddlewareLimitsOnlyRoutesWithRules(t *testing.T) {
+	router := newTestRouter(newTestLimiter(t, map[string]Rule{RouteMatchRequest: {PerUser: 1}}), RouteMatch)
+	for i := 0; i < 3; i++ {
+		if w := send(router, "u1", "10.0.0.1"); w.Code != http.StatusOK {
+			t.Fatalf("request %d status = %d, want 200 on a route without a rule", i, w.Code)
+		}
+	}
+
+	router = newTestRouter(nil, RouteMatchRequest)
+	if w := send(router, "u1", "10.0.0.1"); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 with no limiter", w.Code)
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, []repository.Bucket, time.Time) (time.Duration, error) {
+	return 0, errors.New("connection refused")
+}
+
+func TestMiddlewareAllowsRequestsWhenStoreFails(t *testing.T) {
+	limiter := New(failingStore{}, Options{Rules: map[string]Rule{RouteMatchRequest: {PerUser: 1, PerIP: 1}}})
+	router := newTestRouter(limiter, RouteMatchRequest)
+	for i := 0; i < 2; i++ {
+		if w := send(router, "u1", "10.0.0.1"); w.Code != http.StatusOK {
+			t.Fatalf("request %d status = %d, want 200 while Redis is unavailable", i, w.Code)
+		}
+	}
+}
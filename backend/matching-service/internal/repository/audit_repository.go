@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"matching-service/internal/constants"
+	"matching-service/internal/models"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// AuditLogRepository records admin actions
+type AuditLogRepository interface {
+	Append(ctx context.Context, entry models.AuditEntry) error
+	// Recent returns up to limit entries, newest first
+	Recent(ctx context.Context, limit int) ([]models.AuditEntry, error)
+}
+
+// maxAuditEntries bounds the audit stream. Redis trims it lazily, so slightly more may be kept.
+const maxAuditEntries = 100000
+
+// auditEntryField is the stream field holding an entry's JSON
+const auditEntryField = "entry"
+
+// RedisAuditLogRepository appends admin actions to a Redis stream, so entries survive
+// restarts and every replica reads the same log
+type RedisAuditLogRepository struct {
+	redis *redis.Client
+}
+
+func NewRedisAuditLogRepository(client *redis.Client) *RedisAuditLogRepository {
+	return &RedisAuditLogRepository{redis: client}
+}
+
+func (r *RedisAuditLogRepository) Append(ctx context.Context, entry models.AuditEntry) error {
+	payload, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	err = r.redis.XAdd(ctx, &redis.XAddArgs{
+		Stream:       constants.AuditLogKey,
+		MaxLenApprox: maxAuditEntries,
+		Values:       map[string]interface{}{auditEntryField: payload},
+	}).Err()
+	if err != nil {
+		return fmt.Errorf("failed to write audit log: %w", err)
+	}
+	return nil
+}
+
+func (r *RedisAuditLogRepository) Recent(ctx context.Context, limit int) ([]models.AuditEntry, error) {
+	messages, err := r.redis.XRevRangeN(ctx, constants.AuditLogKey, "+", "-", int64(limit)).Result()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]models.AuditEntry, 0, len(messages))
+	for _, message := range messages {
+		payload, ok := message.Values[auditEntryField].(string)
+		if !ok {
+			continue
+		}
+		var entry models.AuditEntry
+		if err := json.Unmarshal([]byte(payload), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
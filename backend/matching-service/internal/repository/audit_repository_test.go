@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"matching-service/internal/models"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestAuditLogIsSharedByReplicas(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx := context.Background()
+	replica := func() *RedisAuditLogRepository {
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() { client.Close() })
+		return NewRedisAuditLogRepository(client)
+	}
+
+	writer := replica()
+	for _, action := range []string{models.AuditDrainQueue, models.AuditForcePair, models.AuditCancelMatch} {
+		if err := writer.Append(ctx, models.AuditEntry{Actor: "admin", Action: action, Success: true}); err != nil {
+			t.Fatalf("Append returned error: %v", err)
+		}
+	}
+
+	// Another replica, or this one after a restart, reads the same log
+	entries, err := replica().Recent(ctx, 2)
+	if err != nil {
+		t.Fatalf("Recent returned error: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Action != models.AuditCancelMatch || entries[1].Action != models.AuditForcePair {
+		t.Fatalf("entries = %+v, want the two newest, newest first", entries)
+	}
+}
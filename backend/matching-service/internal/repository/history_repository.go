@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"matching-service/internal/constants"
+	"matching-service/internal/models"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// MatchHistoryRepository persists finished matches beyond the lifetime of the Redis records
+type MatchHistoryRepository interface {
+	// Save inserts the record or replaces an earlier record with the same matchId
+	Save(ctx context.Context, record models.MatchHistoryRecord) error
+	// ListByUser returns the user's records, newest first, and the total number of records
+	ListByUser(ctx context.Context, userID string, limit, offset int) ([]models.MatchHistoryRecord, int, error)
+}
+
+// RedisMatchHistoryRepository keeps match history in Redis, where every replica sees it.
+// Records are stored by matchId in one hash, and each user has a sorted set of their
+// matchIds scored by when the match formed. Each user keeps only their newest perUser
+// matches, so history is bounded; it survives a Redis restart only if Redis persists its data.
+type RedisMatchHistoryRepository struct {
+	redis   *redis.Client
+	perUser int
+}
+
+func NewRedisMatchHistoryRepository(client *redis.Client, perUser int) *RedisMatchHistoryRepository {
+	return &RedisMatchHistoryRepository{redis: client, perUser: perUser}
+}
+
+// saveHistoryScript stores the record and adds it to each participant's history, trimming
+// every history to the newest entries. A trimmed record is deleted once no participant's
+// history still lists it.
+// KEYS: records hash, then each participant's history
+// ARGV: matchId, payload, score, perUser, history key prefix and suffix
+var saveHistoryScript = redis.NewScript(`
+local limit = tonumber(ARGV[4])
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+for i = 2, #KEYS do
+	redis.call("ZADD", KEYS[i], ARGV[3], ARGV[1])
+	local excess = redis.call("ZCARD", KEYS[i]) - limit
+	if excess > 0 then
+		local trimmed = redis.call("ZRANGE", KEYS[i], 0, excess - 1)
+		redis.call("ZREMRANGEBYRANK", KEYS[i], 0, excess - 1)
+		for _, id in ipairs(trimmed) do
+			local listed = false
+			local payload = redis.call("HGET", KEYS[1], id)
+			if payload then
+				for _, user in ipairs(cjson.decode(payload).userIds) do
+					if redis.call("ZSCORE", ARGV[5] .. user .. ARGV[6], id) then
+						listed = true
+					end
+				end
+			end
+			if not listed then
+				redis.call("HDEL", KEYS[1], id)
+			end
+		end
+	end
+end
+return 1
+`)
+
+func (r *RedisMatchHistoryRepository) Save(ctx context.Context, record models.MatchHistoryRecord) error {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	keys := []string{constants.HistoryRecordsKey}
+	for _, userID := range record.UserIDs {
+		keys = append(keys, userHistoryKey(userID))
+	}
+	prefix := constants.UserKeyPrefix + constants.QueueKeyDelimiter
+	suffix := constants.QueueKeyDelimiter + constants.UserHistoryKeySuffix
+	return saveHistoryScript.Run(ctx, r.redis, keys,
+		record.MatchID, payload, record.MatchedAt.UnixMilli(), r.perUser, prefix, suffix).Err()
+}
+
+func (r *RedisMatchHistoryRepository) ListByUser(ctx context.Context, userID string, limit, offset int) ([]models.MatchHistoryRecord, int, error) {
+	key := userHistoryKey(userID)
+	var total *redis.IntCmd
+	var ids *redis.StringSliceCmd
+	if _, err := r.redis.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		total = pipe.ZCard(ctx, key)
+		ids = pipe.ZRevRange(ctx, key, int64(offset), int64(offset+limit-1))
+		return nil
+	}); err != nil {
+		return nil, 0, err
+	}
+
+	records := []models.MatchHistoryRecord{}
+	if len(ids.Val()) == 0 {
+		return records, int(total.Val()), nil
+	}
+	payloads, err := r.redis.HMGet(ctx, constants.HistoryRecordsKey, ids.Val()...).Result()
+	if err != nil {
+		return nil, 0, err
+	}
+	for _, payload := range payloads {
+		s, ok := payload.(string)
+		if !ok {
+			continue
+		}
+		var record models.MatchHistoryRecord
+		if err := json.Unmarshal([]byte(s), &record); err != nil {
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, int(total.Val()), nil
+}
+
+func userHistoryKey(userID string) string {
+	return strings.Join([]string{constants.UserKeyPrefix, userID, constants.UserHistoryKeySuffix}, constants.QueueKeyDelimiter)
+}
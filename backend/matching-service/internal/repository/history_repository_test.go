@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"matching-service/internal/constants"
+	"matching-service/internal/models"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestMatchHistoryRepositoryPersistsAndPaginates(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	stores := map[string]MatchHistoryRepository{
+		"redis":  NewRedisMatchHistoryRepository(client, 10),
+		"memory": NewMemoryMatchHistoryRepository(),
+	}
+	for name, repo := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			start := time.Now()
+			for i := 0; i < 3; i++ {
+				record := models.MatchHistoryRecord{
+					MatchID:   fmt.Sprintf("m%d", i),
+					UserIDs:   []string{"u1", fmt.Sprintf("p%d", i)},
+					Outcome:   models.OutcomeMatched,
+					MatchedAt: start.Add(time.Duration(i) * time.Minute),
+				}
+				if err := repo.Save(ctx, record); err != nil {
+					t.Fatalf("Save returned error: %v", err)
+				}
+			}
+			// A later save for the same match replaces the outcome
+			cancelled := models.MatchHistoryRecord{MatchID: "m1", UserIDs: []string{"u1", "p1"}, Outcome: models.OutcomeCancelled, MatchedAt: start.Add(time.Minute)}
+			if err := repo.Save(ctx, cancelled); err != nil {
+				t.Fatalf("Save returned error: %v", err)
+			}
+
+			records, total, err := repo.ListByUser(ctx, "u1", 2, 0)
+			if err != nil {
+				t.Fatalf("ListByUser returned error: %v", err)
+			}
+			if total != 3 || len(records) != 2 {
+				t.Fatalf("got %d records of %d, want 2 of 3", len(records), total)
+			}
+			if records[0].MatchID != "m2" || records[1].MatchID != "m1" || records[1].Outcome != models.OutcomeCancelled {
+				t.Fatalf("records = %+v, want m2 then cancelled m1", records)
+			}
+
+			records, _, _ = repo.ListByUser(ctx, "u1", 2, 2)
+			if len(records) != 1 || records[0].MatchID != "m0" {
+				t.Fatalf("second page = %+v, want only m0", records)
+			}
+			if records, total, _ := repo.ListByUser(ctx, "p0", 10, 0); total != 1 || records[0].MatchID != "m0" {
+				t.Fatalf("partner history = %+v (%d), want m0", records, total)
+			}
+			if records, total, _ := repo.ListByUser(ctx, "nobody", 10, 0); total != 0 || records == nil {
+				t.Fatalf("empty history = %#v (%d), want an empty page", records, total)
+			}
+		})
+	}
+}
+
+func TestRedisMatchHistoryIsSharedBetweenReplicas(t *testing.T) {
+	mr := miniredis.RunT(t)
+	newReplica := func() *RedisMatchHistoryRepository {
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() { client.Close() })
+		return NewRedisMatchHistoryRepository(client, 10)
+	}
+	ctx := context.Background()
+
+	record := models.MatchHistoryRecord{MatchID: "m1", UserIDs: []string{"u1", "u2"}, Outcome: models.OutcomeMatched, MatchedAt: time.Now()}
+	if err := newReplica().Save(ctx, record); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	records, total, err := newReplica().ListByUser(ctx, "u2", 10, 0)
+	if err != nil || total != 1 || records[0].MatchID != "m1" {
+		t.Fatalf("history on another replica = %+v (%d), %v; want m1", records, total, err)
+	}
+}
+
+func TestRedisMatchHistoryKeepsEachUsersNewestMatches(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	repo := NewRedisMatchHistoryRepository(client, 2)
+	ctx := context.Background()
+
+	start := time.Now()
+	matches := []struct {
+		id    string
+		users []string
+	}{
+		{"m0", []string{"u1", "p0"}},
+		{"m1", []string{"u1", "p1"}},
+		{"m2", []string{"u1", "p2"}}, // trims m0 from u1, but p0 still lists it
+		{"m3", []string{"p1", "q3"}},
+		{"m4", []string{"p1", "q4"}}, // trims m1 from p1, but u1 still lists it
+		{"m5", []string{"u1", "p5"}}, // trims m1 from u1, the last history listing it
+	}
+	for i, m := range matches {
+		record := models.MatchHistoryRecord{MatchID: m.id, UserIDs: m.users, Outcome: models.OutcomeMatched, MatchedAt: start.Add(time.Duration(i) * time.Minute)}
+		if err := repo.Save(ctx, record); err != nil {
+			t.Fatalf("Save(%s) returned error: %v", m.id, err)
+		}
+	}
+
+	for user, want := range map[string][]string{"u1": {"m5", "m2"}, "p0": {"m0"}, "p1": {"m4", "m3"}} {
+		records, total, err := repo.ListByUser(ctx, user, 10, 0)
+		if err != nil {
+			t.Fatalf("ListByUser(%s) returned error: %v", user, err)
+		}
+		var got []string
+		for _, record := range records {
+			got = append(got, record.MatchID)
+		}
+		if total != len(want) || !slices.Equal(got, want) {
+			t.Fatalf("%s history = %v (%d), want %v", user, got, total, want)
+		}
+	}
+	stored, err := client.HKeys(ctx, constants.HistoryRecordsKey).Result()
+	if err != nil {
+		t.Fatalf("HKeys returned error: %v", err)
+	}
+	slices.Sort(stored)
+	if want := []string{"m0", "m2", "m3", "m4", "m5"}; !slices.Equal(stored, want) {
+		t.Fatalf("stored records = %v, want %v", stored, want)
+	}
+}
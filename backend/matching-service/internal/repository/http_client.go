@@ -0,0 +1,305 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"matching-service/internal/logging"
+	"matching-service/internal/metrics"
+	"matching-service/internal/tracing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrCircuitOpen is returned without calling a dependency whose circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// BreakerState is the state of a dependency's circuit breaker
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"    // Calls go through
+	BreakerOpen     BreakerState = "open"      // Calls fail fast until the cool-down ends
+	BreakerHalfOpen BreakerState = "half-open" // One probe call decides whether to close again
+)
+
+// OutboundOptions configures an OutboundClient. Zero values fall back to the defaults below.
+type OutboundOptions struct {
+	// Name identifies the dependency in errors, logs and the health endpoint
+	Name string
+	// Timeout bounds a single attempt
+	Timeout time.Duration
+	// MaxRetries is how many times a failed GET is retried
+	MaxRetries int
+	// BaseBackoff and MaxBackoff bound the jittered exponential backoff between retries
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// FailureThreshold is how many consecutive failed attempts open the breaker
+	FailureThreshold int
+	// OpenFor is how long the breaker stays open before letting a probe through
+	OpenFor time.Duration
+	// Metrics records the latency of every attempt; nil records nothing
+	Metrics *metrics.Metrics
+}
+
+const (
+	defaultOutboundTimeout  = 5 * time.Second
+	defaultBaseBackoff      = 100 * time.Millisecond
+	defaultMaxBackoff       = 2 * time.Second
+	defaultFailureThreshold = 5
+	defaultOpenFor          = 30 * time.Second
+)
+
+// OutboundClient calls another service over HTTP. GETs are idempotent, so failed attempts
+// (network errors, 429 and 5xx responses) are retried with jittered exponential backoff
+// while the caller's context allows. A circuit breaker stops calling a dependency that keeps
+// failing, so callers fail fast instead of waiting on timeouts.
+type OutboundClient struct {
+	name       string
+	httpClient *http.Client
+	opts       OutboundOptions
+	breaker    *CircuitBreaker
+}
+
+func NewOutboundClient(opts OutboundOptions) *OutboundClient {
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultOutboundTimeout
+	}
+	if opts.MaxRetries < 0 {
+		opts.MaxRetries = 0
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = defaultBaseBackoff
+	}
+	if opts.MaxBackoff < opts.BaseBackoff {
+		opts.MaxBackoff = max(defaultMaxBackoff, opts.BaseBackoff)
+	}
+	return &OutboundClient{
+		name:       opts.Name,
+		httpClient: &http.Client{Timeout: opts.Timeout},
+		opts:       opts,
+		breaker:    NewCircuitBreaker(opts.Name, opts.FailureThreshold, opts.OpenFor),
+	}
+}
+
+// Breaker returns the client's circuit breaker
+func (c *OutboundClient) Breaker() *CircuitBreaker {
+	return c.breaker
+}
+
+// Get fetches url, retrying failed attempts. The final response is returned whatever its
+// status, so callers keep handling status codes themselves; the caller must close its body.
+func (c *OutboundClient) Get(ctx context.Context, url string) (*http.Response, error) {
+	var lastErr error
+	for attempt := 0; ; attempt++ {
+		if !c.breaker.Allow() {
+			if lastErr != nil {
+				return nil, fmt.Errorf("%s: %w (last error: %v)", c.name, ErrCircuitOpen, lastErr)
+			}
+			return nil, fmt.Errorf("%s: %w", c.name, ErrCircuitOpen)
+		}
+
+		start := time.Now()
+		resp, err := c.do(ctx, url)
+		status := 0
+		if err == nil {
+			status = resp.StatusCode
+		}
+		elapsed := time.Since(start)
+		c.opts.Metrics.ObserveOutbound(c.name, status, elapsed)
+		slog.DebugContext(ctx, "outbound request", "service", c.name, "url", url, "attempt", attempt+1,
+			"status", status, "duration_ms", float64(elapsed.Microseconds())/1000, "error", err)
+		retryable := err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		switch {
+		case err != nil && ctx.Err() != nil:
+			// The caller gave up, which says nothing about the dependency's health
+			c.breaker.Abandon()
+		case retryable:
+			c.breaker.Failure()
+		default:
+			c.breaker.Success()
+		}
+		if !retryable || attempt >= c.opts.MaxRetries || ctx.Err() != nil {
+			return resp, err
+		}
+		if err != nil {
+			lastErr = err
+		} else {
+			lastErr = fmt.Errorf("status %d", resp.StatusCode)
+			// Drain so the connection can be reused
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		wait := c.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return nil, fmt.Errorf("%s: giving up before the deadline: %w", c.name, lastErr)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Probe makes a single GET to check the dependency is reachable. Any response below 500
+// counts as up. Probes are neither retried nor recorded by the circuit breaker.
+func (c *OutboundClient) Probe(ctx context.Context, url string) error {
+	resp, err := c.do(ctx, url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("%s returned status %d", c.name, resp.StatusCode)
+	}
+	return nil
+}
+
+// do makes a single attempt inside its own client span, forwarding the request ID and the
+// W3C trace context so one match can be followed across services
+func (c *OutboundClient) do(ctx context.Context, url string) (resp *http.Response, err error) {
+	ctx, span := tracing.Start(ctx, "GET "+c.name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPRequestMethodGet, semconv.URLFull(url), attribute.String("peer.service", c.name)),
+	)
+	defer func() {
+		if resp != nil {
+			span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+			if resp.StatusCode >= 500 {
+				span.SetStatus(codes.Error, fmt.Sprintf("status %d", resp.StatusCode))
+			}
+		}
+		tracing.End(span, err)
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if id := logging.RequestID(ctx); id != "" {
+		req.Header.Set(logging.RequestIDHeader, id)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	return c.httpClient.Do(req)
+}
+
+// backoff picks a random wait up to the exponential backoff for the attempt ("full jitter"),
+// so retries from concurrent matches do not arrive in bursts
+func (c *OutboundClient) backoff(attempt int) time.Duration {
+	ceiling := c.opts.MaxBackoff
+	if attempt < 30 {
+		ceiling = min(c.opts.BaseBackoff<<attempt, c.opts.MaxBackoff)
+	}
+	return time.Duration(rand.Int64N(int64(ceiling) + 1))
+}
+
+// CircuitBreaker opens after a run of consecutive failures and rejects calls until a cool-down
+// has passed. It then lets one probe through: success closes it, failure opens it again.
+type CircuitBreaker struct {
+	name      string
+	threshold int
+	openFor   time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// BreakerStatus is a snapshot of a circuit breaker for the health endpoint
+type BreakerStatus struct {
+	Name     string       `json:"name"`
+	State    BreakerState `json:"state"`
+	Failures int          `json:"consecutiveFailures"`
+}
+
+func NewCircuitBreaker(name string, threshold int, openFor time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		threshold = defaultFailureThreshold
+	}
+	if openFor <= 0 {
+		openFor = defaultOpenFor
+	}
+	return &CircuitBreaker{name: name, threshold: threshold, openFor: openFor, now: time.Now, state: BreakerClosed}
+}
+
+// Allow reports whether a call may go ahead
+func (b *CircuitBreaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.openFor {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		// Only the probe goes through until it reports back
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// Success records a call that reached a healthy dependency
+func (b *CircuitBreaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.state = BreakerClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// Failure records a failed call and opens the breaker once the threshold is reached
+func (b *CircuitBreaker) Failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	b.probing = false
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+	}
+}
+
+// Abandon records a call the caller cancelled or ran out of time for. The failure count is
+// left alone, and a half-open breaker lets the next probe through.
+func (b *CircuitBreaker) Abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// Status returns the breaker's current state. An open breaker whose cool-down has passed
+// reports half-open, since the next call will probe the dependency.
+func (b *CircuitBreaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state := b.state
+	if state == BreakerOpen && b.now().Sub(b.openedAt) >= b.openFor {
+		state = BreakerHalfOpen
+	}
+	return BreakerStatus{Name: b.name, State: state, Failures: b.failures}
+}
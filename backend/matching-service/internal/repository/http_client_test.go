@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"matching-service/internal/logging"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+func newFlakyServer(t *testing.T, failures int32, failStatus int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			w.WriteHeader(failStatus)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestOutboundClientRetriesServerErrors(t *testing.T) {
+	srv, calls := newFlakyServer(t, 2, http.StatusServiceUnavailable)
+	client := NewOutboundClient(OutboundOptions{Name: "test", MaxRetries: 2, BaseBackoff: time.Millisecond})
+
+	resp, err := client.Get(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || calls.Load() != 3 {
+		t.Fatalf("status %d after %d calls, want 200 after 3", resp.StatusCode, calls.Load())
+	}
+	if status := client.Breaker().Status(); status.State != BreakerClosed || status.Failures != 0 {
+		t.Fatalf("breaker = %+v, want closed with no failures", status)
+	}
+}
+
+func TestOutboundClientDoesNotRetryClientErrors(t *testing.T) {
+	srv, calls := newFlakyServer(t, 1, http.StatusNotFound)
+	client := NewOutboundClient(OutboundOptions{Name: "test", MaxRetries: 2, BaseBackoff: time.Millisecond})
+
+	resp, err := client.Get(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound || calls.Load() != 1 {
+		t.Fatalf("status %d after %d calls, want 404 after 1", resp.StatusCode, calls.Load())
+	}
+}
+
+func TestOutboundClientStopsAtDeadline(t *testing.T) {
+	srv, _ := newFlakyServer(t, 100, http.StatusInternalServerError)
+	client := NewOutboundClient(OutboundOptions{Name: "test", MaxRetries: 10, BaseBackoff: time.Second, MaxBackoff: time.Second, FailureThreshold: 100})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := client.Get(ctx, srv.URL); err == nil {
+		t.Fatal("expected an error once the deadline passed")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("Get took %v, want it to stop at the 50ms deadline", elapsed)
+	}
+}
+
+func TestCircuitBreakerOpensAndRecovers(t *testing.T) {
+	srv, calls := newFlakyServer(t, 3, http.StatusInternalServerError)
+	client := NewOutboundClient(OutboundOptions{Name: "test", FailureThreshold: 3, OpenFor: time.Minute})
+	now := time.Now()
+	client.Breaker().now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(context.Background(), srv.URL)
+		if err != nil {
+			t.Fatalf("Get returned error: %v", err)
+		}
+		resp.Body.Close()
+	}
+	if state := client.Breaker().Status().State; state != BreakerOpen {
+		t.Fatalf("state = %s after 3 failures, want open", state)
+	}
+	if _, err := client.Get(context.Background(), srv.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Get with open breaker returned %v, want ErrCircuitOpen", err)
+	}
+	if calls.Load() != 3 {
+		t.Fatalf("an open breaker let a call through: %d calls", calls.Load())
+	}
+
+	// After the cool-down a probe goes through and closes the breaker again
+	now = now.Add(time.Minute)
+	if state := client.Breaker().Status().State; state != BreakerHalfOpen {
+		t.Fatalf("state = %s after cool-down, want half-open", state)
+	}
+	resp, err := client.Get(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("probe returned error: %v", err)
+	}
+	resp.Body.Close()
+	if state := client.Breaker().Status().State; state != BreakerClosed {
+		t.Fatalf("state = %s after a successful probe, want closed", state)
+	}
+}
+
+func TestCircuitBreakerIgnoresCallsTheCallerAbandoned(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	t.Cleanup(srv.Close)
+	client := NewOutboundClient(OutboundOptions{Name: "test", FailureThreshold: 1, Timeout: 100 * time.Millisecond})
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelExpired()
+	for _, ctx := range []context.Context{cancelled, expired} {
+		if _, err := client.Get(ctx, srv.URL); err == nil {
+			t.Fatal("expected an error once the caller gave up")
+		}
+	}
+	if status := client.Breaker().Status(); status.State != BreakerClosed || status.Failures != 0 {
+		t.Fatalf("breaker = %+v, want closed: the caller giving up is not the dependency failing", status)
+	}
+
+	// The client's own per-attempt timeout is a real failure
+	if _, err := client.Get(context.Background(), srv.URL); err == nil {
+		t.Fatal("expected the attempt to time out")
+	}
+	if state := client.Breaker().Status().State; state != BreakerOpen {
+		t.Fatalf("state = %s after a timed out attempt, want open", state)
+	}
+}
+
+func TestOutboundClientForwardsRequestID(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(logging.RequestIDHeader)
+	}))
+	t.Cleanup(srv.Close)
+	client := NewOutboundClient(OutboundOptions{Name: "test"})
+
+	resp, err := client.Get(logging.WithRequestID(context.Background(), "req-1"), srv.URL)
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	resp.Body.Close()
+	if got != "req-1" {
+		t.Fatalf("downstream saw request ID %q, want req-1", got)
+	}
+}
+
+func TestOutboundClientPropagatesTraceContext(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	provider := sdktrace.NewTracerProvider()
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("traceparent")
+	}))
+	t.Cleanup(srv.Close)
+	client := NewOutboundClient(OutboundOptions{Name: "test"})
+
+	ctx, span := provider.Tracer("test").Start(context.Background(), "caller")
+	defer span.End()
+	resp, err := client.Get(ctx, srv.URL)
+	if err != nil {
+		t.Fatalf("Get returned error: %v", err)
+	}
+	resp.Body.Close()
+	if !strings.Contains(got, span.SpanContext().TraceID().String()) {
+		t.Fatalf("traceparent %q does not carry trace %s", got, span.SpanContext().TraceID())
+	}
+}
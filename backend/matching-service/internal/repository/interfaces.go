@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"matching-service/internal/models"
+)
+
+// ErrNotFound is returned when a requested key, match or queue entry does not exist
+var ErrNotFound = errors.New("not found")
+
+// ErrMatchIDInUse is returned when claiming a pair under the ID of an existing match
+var ErrMatchIDInUse = errors.New("match ID already in use")
+
+// ErrUnusableResponse is returned when a dependency answered but refused the request or sent a
+// body that could not be used. Unlike an outage, retrying the same request will not help.
+var ErrUnusableResponse = errors.New("unusable response")
+
+// MatchStore holds the queues, match records and per-user mappings used for matching.
+// MatchRepository implements it on Redis and MemoryMatchStore in memory.
+type MatchStore interface {
+	// Queues
+	Enqueue(ctx context.Context, queueKey, userID string) error
+	RemoveFromQueue(ctx context.Context, queueKey, userID string) error
+	Requeue(ctx context.Context, queueKey, userID string, enqueuedAt time.Time, mappingTTL time.Duration) error
+	GetUserQueueRank(ctx context.Context, queueKey, userID string) (int64, error)
+	GetEnqueueTime(ctx context.Context, queueKey, userID string) (time.Time, error)
+	PeekTwo(ctx context.Context, queueKey string) ([]string, error)
+	PeekOldest(ctx context.Context, queueKey string) (string, time.Time, error)
+	EvictStale(ctx context.Context, queueKey string, cutoff time.Time, requireAlive bool, markerTTL time.Duration) ([]string, error)
+	GetQueueMembers(ctx context.Context, queueKey string) ([]string, error)
+	GetAllQueues(ctx context.Context) ([]models.QueueInfo, error)
+	GetAllQueueUsers(ctx context.Context) (map[string][]string, error)
+
+	// Per-user state
+	SaveUserQueue(ctx context.Context, userID, queueKey string, ttl time.Duration) error
+	GetUserQueue(ctx context.Context, userID string) (string, error)
+	GetUserMatch(ctx context.Context, userID string) (string, error)
+	ClearUserMatch(ctx context.Context, userID, matchID string) error
+	RefreshUserAlive(ctx context.Context, userID string, ttl time.Duration) error
+	MarkUserTimedOut(ctx context.Context, userID, queueKey string, ttl time.Duration) error
+	GetUserTimeout(ctx context.Context, userID string) (string, error)
+	ClearUserTimeout(ctx context.Context, userID string) error
+
+	// Matches
+	ClaimPair(ctx context.Context, queueKeys []string, users []string, matchID string, matchData MatchData, ttl time.Duration) (bool, error)
+	DropPair(ctx context.Context, queueKeys []string, users []string) (bool, error)
+	GetMatchData(ctx context.Context, matchID string) (*MatchData, error)
+	GetMatch(ctx context.Context, matchID string) (*models.MatchResponse, error)
+	UpdateMatch(ctx context.Context, matchID string, update func(*MatchData) error) (*MatchData, error)
+	CancelMatch(ctx context.Context, matchID string) (bool, error)
+	RemovePendingAccept(ctx context.Context, matchID string) error
+	GetOverduePendingAccepts(ctx context.Context, now time.Time) ([]string, error)
+
+	// Coordination between replicas
+	AcquireLease(ctx context.Context, key, owner string, ttl time.Duration) (bool, error)
+	ReleaseLease(ctx context.Context, key, owner string) error
+	PublishUserEvent(ctx context.Context, userID string, event models.MatchEvent) error
+	SubscribeUserEvents(ctx context.Context, userID string) (<-chan models.MatchEvent, func() error, error)
+}
+
+// UserLookup fetches user data from user-service
+type UserLookup interface {
+	GetCompletedQuestions(ctx context.Context, userID string) ([]string, error)
+}
+
+// QuestionLookup fetches candidate questions from question-service
+type QuestionLookup interface {
+	GetQuestionsByDifficultyAndTag(ctx context.Context, difficulty, tag string, size int) ([]Question, error)
+}
+
+var (
+	_ MatchStore     = (*MatchRepository)(nil)
+	_ MatchStore     = (*MemoryMatchStore)(nil)
+	_ UserLookup     = (*UserRepository)(nil)
+	_ UserLookup     = (*MemoryUserLookup)(nil)
+	_ QuestionLookup = (*QuestionRepository)(nil)
+	_ QuestionLookup = (*MemoryQuestionLookup)(nil)
+	_ QuestionLookup = (*CachedQuestionRepository)(nil)
+)
@@ -3,9 +3,11 @@ package repository
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"matching-service/internal/constants"
 	"matching-service/internal/models"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -26,22 +28,26 @@ func NewMatchRepository(redis *redis.Client) *MatchRepository {
 
 func (r *MatchRepository) Enqueue(ctx context.Context, queueKey, userID string) error {
 	score := float64(time.Now().Unix())
-	return r.redis.ZAdd(ctx, queueKey, &redis.Z{
-		Score:  score,
-		Member: userID,
-	}).Err()
+	_, err := r.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, queueKey, &redis.Z{
+			Score:  score,
+			Member: userID,
+		})
+		pipe.SAdd(ctx, constants.ActiveQueuesKey, queueKey)
+		return nil
+	})
+	return err
 }
 
 // SaveUserQueue stores a mapping from userID to their queueKey
 func (r *MatchRepository) SaveUserQueue(ctx context.Context, userID, queueKey string, ttl time.Duration) error {
-	key := strings.Join([]string{constants.UserKeyPrefix, userID, constants.UserQueueKeySuffix}, constants.QueueKeyDelimiter)
-	return r.redis.Set(ctx, key, queueKey, ttl).Err()
+	return r.redis.Set(ctx, userQueueKey(userID), queueKey, ttl).Err()
 }
 
 // GetUserQueue fetches the queueKey for the given userID
 func (r *MatchRepository) GetUserQueue(ctx context.Context, userID string) (string, error) {
-	key := strings.Join([]string{constants.UserKeyPrefix, userID, constants.UserQueueKeySuffix}, constants.QueueKeyDelimiter)
-	return r.redis.Get(ctx, key).Result()
+	queueKey, err := r.redis.Get(ctx, userQueueKey(userID)).Result()
+	return queueKey, notFound(err)
 }
 
 // GetUserQueueRank returns the user's rank (0-based) within a queue, or -1 if not present
@@ -53,165 +59,572 @@ func (r *MatchRepository) GetUserQueueRank(ctx context.Context, queueKey, userID
 	return rank, err
 }
 
-func (r *MatchRepository) PopTwo(ctx context.Context, queueKey string) ([]string, error) {
-	// Only pop if at least 2 users are present; otherwise return empty and keep user(s) queued
-	size, err := r.redis.ZCard(ctx, queueKey).Result()
+// claimPairScript atomically verifies that both users are still queued, removes
+// them from their queues and writes the match record plus both user -> matchId
+// mappings. A match awaiting acceptance is also indexed by its accept deadline.
+// When no match key is supplied the pair is only removed.
+// KEYS: user1QueueKey, user2QueueKey, matchKey, user1MatchKey, user2MatchKey, pendingAcceptKey
+// ARGV: user1, user2, matchJSON, ttlSeconds, acceptDeadline (0 when no acceptance is needed)
+// Returns 1 if the pair was claimed, 0 if either user had already left their queue. An
+// existing match is never overwritten; the claim fails with ErrMatchIDInUse instead.
+var claimPairScript = redis.NewScript(`
+if redis.call("ZSCORE", KEYS[1], ARGV[1]) == false or redis.call("ZSCORE", KEYS[2], ARGV[2]) == false then
+	return 0
+end
+if KEYS[3] ~= "" and redis.call("EXISTS", KEYS[3]) == 1 then
+	return -1
+end
+redis.call("ZREM", KEYS[1], ARGV[1])
+redis.call("ZREM", KEYS[2], ARGV[2])
+if KEYS[3] ~= "" then
+	local ttl = tonumber(ARGV[4])
+	redis.call("SET", KEYS[3], ARGV[3], "EX", ttl)
+	redis.call("SET", KEYS[4], KEYS[3], "EX", ttl)
+	redis.call("SET", KEYS[5], KEYS[3], "EX", ttl)
+	if tonumber(ARGV[5]) > 0 then
+		redis.call("ZADD", KEYS[6], ARGV[5], KEYS[3])
+	end
+end
+return 1
+`)
+
+// PeekTwo returns the two longest-waiting users in a queue without removing them.
+// Returns an empty slice when fewer than 2 users are present.
+func (r *MatchRepository) PeekTwo(ctx context.Context, queueKey string) ([]string, error) {
+	users, err := r.redis.ZRange(ctx, queueKey, 0, 1).Result()
 	if err != nil {
 		return nil, err
 	}
-	if size < 2 {
+	if len(users) < 2 {
 		return []string{}, nil
 	}
-	vals, err := r.redis.ZPopMin(ctx, queueKey, 2).Result()
+	return users, nil
+}
+
+// evictStaleScript removes every member enqueued at or before the cutoff score and, when
+// liveness is required, every member without a liveness key. Users whose queue mapping
+// still points at this queue have it replaced by a timeout marker so status lookups can
+// report the timeout.
+// KEYS: queueKey
+// ARGV: cutoffScore, markerTTLSeconds, userKeyPrefix, delimiter, queueSuffix, timeoutSuffix, aliveSuffix, requireAlive
+// Returns the users that were timed out.
+var evictStaleScript = redis.NewScript(`
+local cutoff = tonumber(ARGV[1])
+local members = redis.call("ZRANGE", KEYS[1], 0, -1, "WITHSCORES")
+local timedOut = {}
+for i = 1, #members, 2 do
+	local user = members[i]
+	local userPrefix = ARGV[3] .. ARGV[4] .. user .. ARGV[4]
+	local stale = tonumber(members[i + 1]) <= cutoff
+	if not stale and ARGV[8] == "1" then
+		stale = redis.call("EXISTS", userPrefix .. ARGV[7]) == 0
+	end
+	if stale then
+		redis.call("ZREM", KEYS[1], user)
+		if redis.call("GET", userPrefix .. ARGV[5]) == KEYS[1] then
+			redis.call("DEL", userPrefix .. ARGV[5])
+			redis.call("SET", userPrefix .. ARGV[6], KEYS[1], "EX", ARGV[2])
+			table.insert(timedOut, user)
+		end
+	end
+end
+return timedOut
+`)
+
+// EvictStale removes users from the queue who were enqueued at or before cutoff (ignored when
+// zero) or, if requireAlive is set, whose liveness has lapsed. Evicted users are marked as
+// timed out for markerTTL. It returns the users that were timed out.
+func (r *MatchRepository) EvictStale(ctx context.Context, queueKey string, cutoff time.Time, requireAlive bool, markerTTL time.Duration) ([]string, error) {
+	ttlSeconds := int64(markerTTL / time.Second)
+	if ttlSeconds <= 0 {
+		ttlSeconds = 1
+	}
+	cutoffScore := int64(-1) // Scores are unix timestamps, so nothing is at or before -1
+	if !cutoff.IsZero() {
+		cutoffScore = cutoff.Unix()
+	}
+	aliveFlag := "0"
+	if requireAlive {
+		aliveFlag = "1"
+	}
+	return evictStaleScript.Run(ctx, r.redis, []string{queueKey},
+		cutoffScore, ttlSeconds,
+		constants.UserKeyPrefix, constants.QueueKeyDelimiter, constants.UserQueueKeySuffix,
+		constants.UserTimeoutKeySuffix, constants.UserAliveKeySuffix, aliveFlag,
+	).StringSlice()
+}
+
+// RefreshUserAlive records that the user is still present for the next ttl.
+func (r *MatchRepository) RefreshUserAlive(ctx context.Context, userID string, ttl time.Duration) error {
+	return r.redis.Set(ctx, userAliveKey(userID), "1", ttl).Err()
+}
+
+// MarkUserTimedOut records that the user timed out of queueKey for ttl.
+func (r *MatchRepository) MarkUserTimedOut(ctx context.Context, userID, queueKey string, ttl time.Duration) error {
+	return r.redis.Set(ctx, userTimeoutKey(userID), queueKey, ttl).Err()
+}
+
+// GetUserTimeout returns the queueKey the user timed out of, if they were evicted recently.
+func (r *MatchRepository) GetUserTimeout(ctx context.Context, userID string) (string, error) {
+	queueKey, err := r.redis.Get(ctx, userTimeoutKey(userID)).Result()
+	return queueKey, notFound(err)
+}
+
+// ClearUserTimeout removes the user's timeout marker, e.g. when they queue again.
+func (r *MatchRepository) ClearUserTimeout(ctx context.Context, userID string) error {
+	return r.redis.Del(ctx, userTimeoutKey(userID)).Err()
+}
+
+// GetQueueMembers returns every user in a queue, longest-waiting first
+func (r *MatchRepository) GetQueueMembers(ctx context.Context, queueKey string) ([]string, error) {
+	return r.redis.ZRange(ctx, queueKey, 0, -1).Result()
+}
+
+// PeekOldest returns the longest-waiting user in a queue and their enqueue time.
+// Returns an empty userID when the queue is empty.
+func (r *MatchRepository) PeekOldest(ctx context.Context, queueKey string) (string, time.Time, error) {
+	vals, err := r.redis.ZRangeWithScores(ctx, queueKey, 0, 0).Result()
 	if err != nil {
-		return nil, err
+		return "", time.Time{}, err
 	}
-	var users []string
-	for _, v := range vals {
-		users = append(users, v.Member.(string))
+	if len(vals) == 0 {
+		return "", time.Time{}, nil
 	}
-	return users, nil
+	return vals[0].Member.(string), time.Unix(int64(vals[0].Score), 0), nil
 }
 
+// GetEnqueueTime returns when the user was added to the given queue.
+func (r *MatchRepository) GetEnqueueTime(ctx context.Context, queueKey, userID string) (time.Time, error) {
+	score, err := r.redis.ZScore(ctx, queueKey, userID).Result()
+	if err != nil {
+		return time.Time{}, notFound(err)
+	}
+	return time.Unix(int64(score), 0), nil
+}
+
+// ClaimPair atomically removes both users from their queues (queueKeys[i] holds users[i])
+// and stores the match record and both user -> matchId mappings. It returns false
+// without writing anything if another caller has already claimed either user.
+func (r *MatchRepository) ClaimPair(ctx context.Context, queueKeys []string, users []string, matchID string, matchData MatchData, ttl time.Duration) (bool, error) {
+	matchJSON, err := json.Marshal(matchData)
+	if err != nil {
+		return false, err
+	}
+	keys := []string{queueKeys[0], queueKeys[1], matchID, userMatchKey(users[0]), userMatchKey(users[1]), constants.PendingAcceptKey}
+	return r.runClaimPair(ctx, keys, users, string(matchJSON), ttl, matchData.AcceptBy)
+}
+
+// DropPair atomically removes both users from their queues without creating a match.
+// It returns false if either user has already left their queue.
+func (r *MatchRepository) DropPair(ctx context.Context, queueKeys []string, users []string) (bool, error) {
+	keys := []string{queueKeys[0], queueKeys[1], "", "", "", ""}
+	return r.runClaimPair(ctx, keys, users, "", 0, 0)
+}
+
+func (r *MatchRepository) runClaimPair(ctx context.Context, keys []string, users []string, matchJSON string, ttl time.Duration, acceptDeadline int64) (bool, error) {
+	ttlSeconds := int64(ttl / time.Second)
+	if ttlSeconds <= 0 {
+		ttlSeconds = 1
+	}
+	claimed, err := claimPairScript.Run(ctx, r.redis, keys, users[0], users[1], matchJSON, ttlSeconds, acceptDeadline).Int()
+	if err != nil {
+		return false, err
+	}
+	if claimed < 0 {
+		return false, ErrMatchIDInUse
+	}
+	return claimed == 1, nil
+}
+
+// MatchData is the match record stored under the matchId
 type MatchData struct {
-	PartnerID  string `json:"partnerId"`
-	QuestionID string `json:"questionId"`
+	UserIDs       []string  `json:"userIds"`
+	Difficulty    string    `json:"difficulty"`
+	Topics        []string  `json:"topics"`
+	QuestionID    string    `json:"questionId"`
+	QuestionTitle string    `json:"questionTitle,omitempty"`
+	QuestionSlug  string    `json:"questionSlug,omitempty"`
+	TopicCoverage []string  `json:"topicCoverage,omitempty"`
+	Relaxed       []string  `json:"relaxed,omitempty"`
+	Status        string    `json:"status"`
+	CreatedAt     time.Time `json:"createdAt"`
+	ExpiresAt     time.Time `json:"expiresAt"`
+	// Queues and original enqueue times per user, so they can be requeued with their priority
+	QueueKeys  []string `json:"queueKeys,omitempty"`
+	EnqueuedAt []int64  `json:"enqueuedAt,omitempty"`
+	// AcceptedBy lists who has accepted a pending match; AcceptBy is the unix deadline to accept
+	AcceptedBy []string `json:"acceptedBy,omitempty"`
+	AcceptBy   int64    `json:"acceptBy,omitempty"`
 }
 
-func (r *MatchRepository) GetMatch(ctx context.Context, matchID string) (*models.MatchResponse, error) {
+// Response converts the stored record into the API representation
+func (m *MatchData) Response(matchID string) *models.MatchResponse {
+	createdAt, expiresAt := m.CreatedAt, m.ExpiresAt
+	return &models.MatchResponse{
+		MatchID:       matchID,
+		UserIDs:       m.UserIDs,
+		Difficulty:    m.Difficulty,
+		Topics:        m.Topics,
+		QuestionID:    m.QuestionID,
+		QuestionTitle: m.QuestionTitle,
+		QuestionSlug:  m.QuestionSlug,
+		TopicCoverage: m.TopicCoverage,
+		Status:        m.Status,
+		Relaxed:       m.Relaxed,
+		CreatedAt:     &createdAt,
+		ExpiresAt:     &expiresAt,
+		AcceptBy:      m.AcceptBy,
+	}
+}
+
+// GetMatchData returns the stored match record.
+func (r *MatchRepository) GetMatchData(ctx context.Context, matchID string) (*MatchData, error) {
 	matchJSON, err := r.redis.Get(ctx, matchID).Result()
 	if err != nil {
-		return nil, err
+		return nil, notFound(err)
 	}
 
 	var matchData MatchData
 	if err := json.Unmarshal([]byte(matchJSON), &matchData); err != nil {
 		return nil, err
 	}
+	return &matchData, nil
+}
 
-	return &models.MatchResponse{
-		MatchID:    matchID,
-		UserIDs:    []string{matchData.PartnerID},
-		QuestionID: matchData.QuestionID,
-		Status:     "matched",
-	}, nil
+func (r *MatchRepository) GetMatch(ctx context.Context, matchID string) (*models.MatchResponse, error) {
+	matchData, err := r.GetMatchData(ctx, matchID)
+	if err != nil {
+		return nil, err
+	}
+	return matchData.Response(matchID), nil
+}
+
+// UpdateMatch applies update to the stored match record inside an optimistic transaction,
+// retrying if the record changes concurrently. Errors returned by update abort the write.
+func (r *MatchRepository) UpdateMatch(ctx context.Context, matchID string, update func(*MatchData) error) (*MatchData, error) {
+	var updated MatchData
+	txf := func(tx *redis.Tx) error {
+		matchJSON, err := tx.Get(ctx, matchID).Result()
+		if err != nil {
+			return notFound(err)
+		}
+		var matchData MatchData
+		if err := json.Unmarshal([]byte(matchJSON), &matchData); err != nil {
+			return err
+		}
+		if err := update(&matchData); err != nil {
+			return err
+		}
+		payload, err := json.Marshal(matchData)
+		if err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, matchID, payload, redis.KeepTTL)
+			return nil
+		})
+		updated = matchData
+		return err
+	}
+
+	for attempt := 0; attempt < maxTxAttempts; attempt++ {
+		err := r.redis.Watch(ctx, txf, matchID)
+		if err == redis.TxFailedErr {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &updated, nil
+	}
+	return nil, redis.TxFailedErr
 }
 
-func (r *MatchRepository) CancelMatch(ctx context.Context, matchID string) error {
-	return r.redis.Del(ctx, matchID).Err()
+// maxTxAttempts bounds retries of optimistic transactions that lose a race
+const maxTxAttempts = 5
+
+// RemovePendingAccept drops a match from the pending acceptance index.
+func (r *MatchRepository) RemovePendingAccept(ctx context.Context, matchID string) error {
+	return r.redis.ZRem(ctx, constants.PendingAcceptKey, matchID).Err()
 }
 
-// RemoveFromQueue removes a user from the given queue
-func (r *MatchRepository) RemoveFromQueue(ctx context.Context, queueKey, userID string) error {
-	return r.redis.ZRem(ctx, queueKey, userID).Err()
+// GetOverduePendingAccepts returns pending matches whose accept deadline is at or before now.
+func (r *MatchRepository) GetOverduePendingAccepts(ctx context.Context, now time.Time) ([]string, error) {
+	return r.redis.ZRangeByScore(ctx, constants.PendingAcceptKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.Unix(), 10),
+	}).Result()
 }
 
-// SaveMatch stores match data (partnerId and questionId) with a TTL so clients can poll.
-func (r *MatchRepository) SaveMatch(ctx context.Context, matchID string, partnerID string, questionID string, ttl time.Duration) error {
-	matchData := MatchData{
-		PartnerID:  partnerID,
-		QuestionID: questionID,
-	}
-	matchJSON, err := json.Marshal(matchData)
+// Requeue puts a user back into a queue with their original enqueue time as the score, so
+// they keep their place in line, and clears their match mapping.
+func (r *MatchRepository) Requeue(ctx context.Context, queueKey, userID string, enqueuedAt time.Time, mappingTTL time.Duration) error {
+	_, err := r.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, queueKey, &redis.Z{Score: float64(enqueuedAt.Unix()), Member: userID})
+		pipe.SAdd(ctx, constants.ActiveQueuesKey, queueKey)
+		pipe.Set(ctx, userQueueKey(userID), queueKey, mappingTTL)
+		pipe.Del(ctx, userMatchKey(userID))
+		return nil
+	})
+	return err
+}
+
+// clearUserMatchScript deletes a user -> matchId mapping only if it still points at the given match.
+var clearUserMatchScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// ClearUserMatch removes the user's matchId mapping if it still points at matchID.
+func (r *MatchRepository) ClearUserMatch(ctx context.Context, userID, matchID string) error {
+	return clearUserMatchScript.Run(ctx, r.redis, []string{userMatchKey(userID)}, matchID).Err()
+}
+
+// CancelMatch deletes the match record. It returns false if the record was already gone,
+// so concurrent cancellations can tell which one took effect.
+func (r *MatchRepository) CancelMatch(ctx context.Context, matchID string) (bool, error) {
+	deleted, err := r.redis.Del(ctx, matchID).Result()
 	if err != nil {
-		return err
+		return false, err
 	}
-	return r.redis.Set(ctx, matchID, matchJSON, ttl).Err()
+	return deleted > 0, nil
+}
+
+// RemoveFromQueue removes a user from the given queue
+func (r *MatchRepository) RemoveFromQueue(ctx context.Context, queueKey, userID string) error {
+	return r.redis.ZRem(ctx, queueKey, userID).Err()
 }
 
 // SaveUserMatch stores userId -> matchId with TTL so a user can poll by userId.
 func (r *MatchRepository) SaveUserMatch(ctx context.Context, userID string, matchID string, ttl time.Duration) error {
-	key := strings.Join([]string{constants.UserKeyPrefix, userID, constants.UserMatchIDKeySuffix}, constants.QueueKeyDelimiter)
+	key := userMatchKey(userID)
 	return r.redis.Set(ctx, key, matchID, ttl).Err()
 }
 
 // GetUserMatch returns the matchId for a given user, if present.
 func (r *MatchRepository) GetUserMatch(ctx context.Context, userID string) (string, error) {
-	key := strings.Join([]string{constants.UserKeyPrefix, userID, constants.UserMatchIDKeySuffix}, constants.QueueKeyDelimiter)
-	return r.redis.Get(ctx, key).Result()
+	matchID, err := r.redis.Get(ctx, userMatchKey(userID)).Result()
+	return matchID, notFound(err)
 }
 
-// GetAllQueues retrieves all queue information using SCAN for better performance
-func (r *MatchRepository) GetAllQueues(ctx context.Context) ([]models.QueueInfo, error) {
-	var cursor uint64
-	var queues []models.QueueInfo
-	queueMap := make(map[string]bool) // To track unique queues
+// acquireLeaseScript takes the lease if it is free or extends it if the caller already owns it.
+// KEYS: leaseKey  ARGV: owner, ttlMillis
+var acquireLeaseScript = redis.NewScript(`
+local holder = redis.call("GET", KEYS[1])
+if holder == false then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+	return 1
+end
+if holder == ARGV[1] then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	return 1
+end
+return 0
+`)
+
+// releaseLeaseScript deletes the lease only if the caller still owns it.
+var releaseLeaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+// AcquireLease takes or renews a lease held by owner for ttl. It returns false if another owner holds it.
+func (r *MatchRepository) AcquireLease(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	held, err := acquireLeaseScript.Run(ctx, r.redis, []string{key}, owner, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return held == 1, nil
+}
 
-	pattern := constants.QueueKeyPrefix + constants.QueueKeyDelimiter + "*"
+// ReleaseLease gives up a lease if it is still held by owner.
+func (r *MatchRepository) ReleaseLease(ctx context.Context, key, owner string) error {
+	return releaseLeaseScript.Run(ctx, r.redis, []string{key}, owner).Err()
+}
 
-	// Use SCAN instead of KEYS for better performance
-	for {
-		keys, newCursor, err := r.redis.Scan(ctx, cursor, pattern, constants.ScanBatchSize).Result()
-		if err != nil {
-			log.Printf("Error scanning Redis keys: %v", err)
-			return nil, err
-		}
+// PublishUserEvent publishes a match event on the user's channel so whichever replica
+// holds the user's connection can deliver it.
+func (r *MatchRepository) PublishUserEvent(ctx context.Context, userID string, event models.MatchEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return r.redis.Publish(ctx, userEventsChannel(userID), payload).Err()
+}
 
-		for _, queueKey := range keys {
-			if queueMap[queueKey] {
-				continue // Skip if already processed
-			}
-			queueMap[queueKey] = true
+// SubscribeUserEvents subscribes to the user's event channel. The returned channel is
+// closed once the subscription is closed with the returned close function.
+func (r *MatchRepository) SubscribeUserEvents(ctx context.Context, userID string) (<-chan models.MatchEvent, func() error, error) {
+	pubsub := r.redis.Subscribe(ctx, userEventsChannel(userID))
+	// Wait for the subscription to be confirmed so no event published afterwards is missed
+	if _, err := pubsub.Receive(ctx); err != nil {
+		_ = pubsub.Close()
+		return nil, nil, err
+	}
 
-			// Parse queue key format
-			parts := strings.Split(queueKey, constants.QueueKeyDelimiter)
-			if len(parts) != constants.QueueKeyParts || parts[0] != constants.QueueKeyPrefix {
-				log.Printf("Skipping malformed queue key: %s", queueKey)
+	events := make(chan models.MatchEvent)
+	go func() {
+		defer close(events)
+		for msg := range pubsub.Channel() {
+			var event models.MatchEvent
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				slog.WarnContext(ctx, "skipping malformed event", "user_id", userID, "error", err)
 				continue
 			}
-
-			// Get queue size
-			size, err := r.redis.ZCard(ctx, queueKey).Result()
-			if err != nil {
-				log.Printf("Error getting size for queue %s: %v", queueKey, err)
-				continue // Log error but continue processing other queues
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
 			}
-
-			queues = append(queues, models.QueueInfo{
-				Key:        queueKey,
-				Difficulty: parts[1],
-				Topics:     parts[2],
-				Size:       size,
-			})
 		}
+	}()
+	return events, pubsub.Close, nil
+}
 
-		cursor = newCursor
-		if cursor == 0 {
-			break // Scan complete
-		}
+// notFound translates a missing Redis key into ErrNotFound
+func notFound(err error) error {
+	if err == redis.Nil {
+		return ErrNotFound
 	}
+	return err
+}
 
-	return queues, nil
+func userEventsChannel(userID string) string {
+	return strings.Join([]string{constants.UserKeyPrefix, userID, constants.UserEventsSuffix}, constants.QueueKeyDelimiter)
 }
 
-// GetAllQueueUsers returns all users currently in all queues
-func (r *MatchRepository) GetAllQueueUsers(ctx context.Context) (map[string][]string, error) {
+func userQueueKey(userID string) string {
+	return strings.Join([]string{constants.UserKeyPrefix, userID, constants.UserQueueKeySuffix}, constants.QueueKeyDelimiter)
+}
+
+func userAliveKey(userID string) string {
+	return strings.Join([]string{constants.UserKeyPrefix, userID, constants.UserAliveKeySuffix}, constants.QueueKeyDelimiter)
+}
+
+func userTimeoutKey(userID string) string {
+	return strings.Join([]string{constants.UserKeyPrefix, userID, constants.UserTimeoutKeySuffix}, constants.QueueKeyDelimiter)
+}
+
+func userMatchKey(userID string) string {
+	return strings.Join([]string{constants.UserKeyPrefix, userID, constants.UserMatchIDKeySuffix}, constants.QueueKeyDelimiter)
+}
+
+// pruneQueueScript drops a queue from the active queue index if it is empty. Enqueue adds
+// the user and indexes the queue in one transaction, so a queue is never left unindexed.
+// KEYS: activeQueuesKey, queueKey
+var pruneQueueScript = redis.NewScript(`
+if redis.call("ZCARD", KEYS[2]) == 0 then
+	return redis.call("SREM", KEYS[1], KEYS[2])
+end
+return 0
+`)
+
+// activeQueues lists the indexed queues with their sizes, pruning any that have emptied
+func (r *MatchRepository) activeQueues(ctx context.Context) ([]string, []int64, error) {
+	keys, err := r.redis.SMembers(ctx, constants.ActiveQueuesKey).Result()
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Strings(keys)
+
+	pipe := r.redis.Pipeline()
+	sizes := make([]*redis.IntCmd, len(keys))
+	for i, queueKey := range keys {
+		sizes[i] = pipe.ZCard(ctx, queueKey)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, nil, err
+	}
+
+	var active []string
+	var activeSizes []int64
+	for i, queueKey := range keys {
+		if size := sizes[i].Val(); size > 0 {
+			active = append(active, queueKey)
+			activeSizes = append(activeSizes, size)
+			continue
+		}
+		if err := pruneQueueScript.Run(ctx, r.redis, []string{constants.ActiveQueuesKey, queueKey}).Err(); err != nil {
+			slog.WarnContext(ctx, "failed to prune empty queue", "queue", queueKey, "error", err)
+		}
+	}
+	return active, activeSizes, nil
+}
+
+// IndexQueues adds every existing queue to the active queue index. It scans the keyspace,
+// so it is only run once at startup to pick up queues created before the index existed.
+func (r *MatchRepository) IndexQueues(ctx context.Context) error {
 	var cursor uint64
-	queueUsers := make(map[string][]string)
 	pattern := constants.QueueKeyPrefix + constants.QueueKeyDelimiter + "*"
-
-	// Use SCAN instead of KEYS for better performance
 	for {
 		keys, newCursor, err := r.redis.Scan(ctx, cursor, pattern, constants.ScanBatchSize).Result()
 		if err != nil {
-			log.Printf("Error scanning Redis keys: %v", err)
-			return nil, err
+			return err
 		}
-
-		for _, queueKey := range keys {
-			// Get all users in this queue
-			users, err := r.redis.ZRange(ctx, queueKey, 0, -1).Result()
-			if err != nil {
-				log.Printf("Error getting users for queue %s: %v", queueKey, err)
-				continue // Skip this queue if there's an error
+		if len(keys) > 0 {
+			members := make([]interface{}, len(keys))
+			for i, key := range keys {
+				members[i] = key
+			}
+			if err := r.redis.SAdd(ctx, constants.ActiveQueuesKey, members...).Err(); err != nil {
+				return err
 			}
-			queueUsers[queueKey] = users
 		}
-
 		cursor = newCursor
 		if cursor == 0 {
-			break // Scan complete
+			return nil
+		}
+	}
+}
+
+// GetAllQueues retrieves every non-empty queue from the active queue index
+func (r *MatchRepository) GetAllQueues(ctx context.Context) ([]models.QueueInfo, error) {
+	keys, sizes, err := r.activeQueues(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to list active queues", "error", err)
+		return nil, err
+	}
+
+	var queues []models.QueueInfo
+	for i, queueKey := range keys {
+		// Parse queue key format
+		parts := strings.Split(queueKey, constants.QueueKeyDelimiter)
+		if len(parts) != constants.QueueKeyParts || parts[0] != constants.QueueKeyPrefix {
+			slog.WarnContext(ctx, "skipping malformed queue key", "queue", queueKey)
+			continue
+		}
+		queues = append(queues, models.QueueInfo{
+			Key:        queueKey,
+			Difficulty: parts[1],
+			Topics:     parts[2],
+			Size:       sizes[i],
+		})
+	}
+
+	return queues, nil
+}
+
+// GetAllQueueUsers returns all users currently in all queues
+func (r *MatchRepository) GetAllQueueUsers(ctx context.Context) (map[string][]string, error) {
+	keys, _, err := r.activeQueues(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to list active queues", "error", err)
+		return nil, err
+	}
+
+	queueUsers := make(map[string][]string)
+	for _, queueKey := range keys {
+		// Get all users in this queue
+		users, err := r.redis.ZRange(ctx, queueKey, 0, -1).Result()
+		if err != nil {
+			slog.ErrorContext(ctx, "failed to get queue users", "queue", queueKey, "error", err)
+			continue // Skip this queue if there's an error
 		}
+		queueUsers[queueKey] = users
 	}
 
 	return queueUsers, nil
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"matching-service/internal/constants"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestClaimPairNeverOverwritesAMatch(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	stores := map[string]MatchStore{
+		"redis":  NewMatchRepository(client),
+		"memory": NewMemoryMatchStore(),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			queues := []string{"queue:easy:array", "queue:easy:array"}
+			enqueue := func(users ...string) {
+				for _, user := range users {
+					if err := store.Enqueue(ctx, queues[0], user); err != nil {
+						t.Fatalf("Enqueue: %v", err)
+					}
+				}
+			}
+
+			enqueue("u1", "u2")
+			claimed, err := store.ClaimPair(ctx, queues, []string{"u1", "u2"}, "match:same", MatchData{QuestionID: "q1"}, time.Minute)
+			if err != nil || !claimed {
+				t.Fatalf("first claim = %v, %v; want claimed", claimed, err)
+			}
+
+			enqueue("u3", "u4")
+			claimed, err = store.ClaimPair(ctx, queues, []string{"u3", "u4"}, "match:same", MatchData{QuestionID: "q2"}, time.Minute)
+			if !errors.Is(err, ErrMatchIDInUse) || claimed {
+				t.Fatalf("claim under an existing ID = %v, %v; want ErrMatchIDInUse", claimed, err)
+			}
+			match, err := store.GetMatchData(ctx, "match:same")
+			if err != nil || match.QuestionID != "q1" {
+				t.Fatalf("match = %+v, %v; want the first match untouched", match, err)
+			}
+			if users, _ := store.PeekTwo(ctx, queues[0]); len(users) != 2 {
+				t.Fatalf("queued users = %v, want u3 and u4 still queued", users)
+			}
+		})
+	}
+}
+
+func TestGetAllQueuesUsesTheActiveQueueIndex(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	repo := NewMatchRepository(client)
+	ctx := context.Background()
+
+	if err := repo.Enqueue(ctx, "queue:easy:array", "u1"); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	if err := repo.Enqueue(ctx, "queue:hard:graph", "u2"); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+	// A queue written before the index existed is only listed once indexed
+	if _, err := mr.ZAdd("queue:medium:tree", 1, "u3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.RemoveFromQueue(ctx, "queue:hard:graph", "u2"); err != nil {
+		t.Fatalf("RemoveFromQueue: %v", err)
+	}
+
+	queues, err := repo.GetAllQueues(ctx)
+	if err != nil {
+		t.Fatalf("GetAllQueues: %v", err)
+	}
+	if len(queues) != 1 || queues[0].Key != "queue:easy:array" || queues[0].Size != 1 {
+		t.Fatalf("queues = %+v, want only queue:easy:array", queues)
+	}
+	if members, _ := mr.Members(constants.ActiveQueuesKey); len(members) != 1 {
+		t.Fatalf("index = %v, want the emptied queue pruned", members)
+	}
+
+	if err := repo.IndexQueues(ctx); err != nil {
+		t.Fatalf("IndexQueues: %v", err)
+	}
+	users, err := repo.GetAllQueueUsers(ctx)
+	if err != nil {
+		t.Fatalf("GetAllQueueUsers: %v", err)
+	}
+	if len(users) != 2 || users["queue:medium:tree"][0] != "u3" {
+		t.Fatalf("queue users = %v, want the pre-existing queue indexed", users)
+	}
+}
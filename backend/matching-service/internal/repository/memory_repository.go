@@ -0,0 +1,605 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"matching-service/internal/constants"
+	"matching-service/internal/models"
+)
+
+// MemoryMatchStore is an in-memory MatchStore with the same semantics as MatchRepository.
+// It is meant for tests and local runs of a single replica; nothing is persisted.
+type MemoryMatchStore struct {
+	mu          sync.Mutex
+	queues      map[string]map[string]float64
+	values      map[string]memoryValue
+	matches     map[string]memoryMatch
+	pending     map[string]int64
+	subscribers map[string][]chan models.MatchEvent
+}
+
+type memoryValue struct {
+	value     string
+	expiresAt time.Time // Zero means no expiry
+}
+
+type memoryMatch struct {
+	data      []byte
+	expiresAt time.Time
+}
+
+// memoryEventBuffer is how many undelivered events a subscriber can hold before new ones are dropped
+const memoryEventBuffer = 16
+
+func NewMemoryMatchStore() *MemoryMatchStore {
+	return &MemoryMatchStore{
+		queues:      make(map[string]map[string]float64),
+		values:      make(map[string]memoryValue),
+		matches:     make(map[string]memoryMatch),
+		pending:     make(map[string]int64),
+		subscribers: make(map[string][]chan models.MatchEvent),
+	}
+}
+
+func expired(expiresAt time.Time) bool {
+	return !expiresAt.IsZero() && !time.Now().Before(expiresAt)
+}
+
+func expiryFrom(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+// get returns a live value; callers must hold mu
+func (s *MemoryMatchStore) get(key string) (string, bool) {
+	v, ok := s.values[key]
+	if !ok {
+		return "", false
+	}
+	if expired(v.expiresAt) {
+		delete(s.values, key)
+		return "", false
+	}
+	return v.value, true
+}
+
+func (s *MemoryMatchStore) set(key, value string, ttl time.Duration) {
+	s.values[key] = memoryValue{value: value, expiresAt: expiryFrom(ttl)}
+}
+
+// members returns a queue's users ordered by score, then by user ID, like ZRANGE
+func (s *MemoryMatchStore) members(queueKey string) []string {
+	queue := s.queues[queueKey]
+	users := make([]string, 0, len(queue))
+	for userID := range queue {
+		users = append(users, userID)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if queue[users[i]] != queue[users[j]] {
+			return queue[users[i]] < queue[users[j]]
+		}
+		return users[i] < users[j]
+	})
+	return users
+}
+
+func (s *MemoryMatchStore) zadd(queueKey, userID string, score float64) {
+	if s.queues[queueKey] == nil {
+		s.queues[queueKey] = make(map[string]float64)
+	}
+	s.queues[queueKey][userID] = score
+}
+
+// zrem removes a user from a queue, deleting the queue once it is empty as Redis does
+func (s *MemoryMatchStore) zrem(queueKey, userID string) {
+	delete(s.queues[queueKey], userID)
+	if len(s.queues[queueKey]) == 0 {
+		delete(s.queues, queueKey)
+	}
+}
+
+func (s *MemoryMatchStore) queued(queueKey, userID string) bool {
+	_, ok := s.queues[queueKey][userID]
+	return ok
+}
+
+// loadMatch decodes a live match record; callers must hold mu
+func (s *MemoryMatchStore) loadMatch(matchID string) (*MatchData, error) {
+	m, ok := s.matches[matchID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if expired(m.expiresAt) {
+		delete(s.matches, matchID)
+		return nil, ErrNotFound
+	}
+	var data MatchData
+	if err := json.Unmarshal(m.data, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+func (s *MemoryMatchStore) Enqueue(ctx context.Context, queueKey, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.zadd(queueKey, userID, float64(time.Now().Unix()))
+	return nil
+}
+
+func (s *MemoryMatchStore) RemoveFromQueue(ctx context.Context, queueKey, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.zrem(queueKey, userID)
+	return nil
+}
+
+func (s *MemoryMatchStore) Requeue(ctx context.Context, queueKey, userID string, enqueuedAt time.Time, mappingTTL time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.zadd(queueKey, userID, float64(enqueuedAt.Unix()))
+	s.set(userQueueKey(userID), queueKey, mappingTTL)
+	delete(s.values, userMatchKey(userID))
+	return nil
+}
+
+func (s *MemoryMatchStore) GetUserQueueRank(ctx context.Context, queueKey, userID string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, id := range s.members(queueKey) {
+		if id == userID {
+			return int64(i), nil
+		}
+	}
+	return -1, nil
+}
+
+func (s *MemoryMatchStore) GetEnqueueTime(ctx context.Context, queueKey, userID string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	score, ok := s.queues[queueKey][userID]
+	if !ok {
+		return time.Time{}, ErrNotFound
+	}
+	return time.Unix(int64(score), 0), nil
+}
+
+func (s *MemoryMatchStore) PeekTwo(ctx context.Context, queueKey string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	users := s.members(queueKey)
+	if len(users) < 2 {
+		return []string{}, nil
+	}
+	return users[:2], nil
+}
+
+func (s *MemoryMatchStore) PeekOldest(ctx context.Context, queueKey string) (string, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	users := s.members(queueKey)
+	if len(users) == 0 {
+		return "", time.Time{}, nil
+	}
+	return users[0], time.Unix(int64(s.queues[queueKey][users[0]]), 0), nil
+}
+
+func (s *MemoryMatchStore) EvictStale(ctx context.Context, queueKey string, cutoff time.Time, requireAlive bool, markerTTL time.Duration) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if markerTTL < time.Second {
+		markerTTL = time.Second
+	}
+	var timedOut []string
+	for _, userID := range s.members(queueKey) {
+		stale := !cutoff.IsZero() && s.queues[queueKey][userID] <= float64(cutoff.Unix())
+		if !stale && requireAlive {
+			_, alive := s.get(userAliveKey(userID))
+			stale = !alive
+		}
+		if !stale {
+			continue
+		}
+		s.zrem(queueKey, userID)
+		if current, ok := s.get(userQueueKey(userID)); ok && current == queueKey {
+			delete(s.values, userQueueKey(userID))
+			s.set(userTimeoutKey(userID), queueKey, markerTTL)
+			timedOut = append(timedOut, userID)
+		}
+	}
+	return timedOut, nil
+}
+
+func (s *MemoryMatchStore) GetQueueMembers(ctx context.Context, queueKey string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.members(queueKey), nil
+}
+
+func (s *MemoryMatchStore) GetAllQueues(ctx context.Context) ([]models.QueueInfo, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var queues []models.QueueInfo
+	for _, queueKey := range s.queueKeys() {
+		parts := strings.Split(queueKey, constants.QueueKeyDelimiter)
+		if len(parts) != constants.QueueKeyParts || parts[0] != constants.QueueKeyPrefix {
+			continue
+		}
+		queues = append(queues, models.QueueInfo{
+			Key:        queueKey,
+			Difficulty: parts[1],
+			Topics:     parts[2],
+			Size:       int64(len(s.queues[queueKey])),
+		})
+	}
+	return queues, nil
+}
+
+func (s *MemoryMatchStore) GetAllQueueUsers(ctx context.Context) (map[string][]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	queueUsers := make(map[string][]string)
+	for _, queueKey := range s.queueKeys() {
+		queueUsers[queueKey] = s.members(queueKey)
+	}
+	return queueUsers, nil
+}
+
+// queueKeys lists the queues in a stable order
+func (s *MemoryMatchStore) queueKeys() []string {
+	keys := make([]string, 0, len(s.queues))
+	for key := range s.queues {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (s *MemoryMatchStore) SaveUserQueue(ctx context.Context, userID, queueKey string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(userQueueKey(userID), queueKey, ttl)
+	return nil
+}
+
+func (s *MemoryMatchStore) GetUserQueue(ctx context.Context, userID string) (string, error) {
+	return s.getValue(userQueueKey(userID))
+}
+
+func (s *MemoryMatchStore) GetUserMatch(ctx context.Context, userID string) (string, error) {
+	return s.getValue(userMatchKey(userID))
+}
+
+func (s *MemoryMatchStore) ClearUserMatch(ctx context.Context, userID, matchID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if current, ok := s.get(userMatchKey(userID)); ok && current == matchID {
+		delete(s.values, userMatchKey(userID))
+	}
+	return nil
+}
+
+func (s *MemoryMatchStore) RefreshUserAlive(ctx context.Context, userID string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(userAliveKey(userID), "1", ttl)
+	return nil
+}
+
+func (s *MemoryMatchStore) MarkUserTimedOut(ctx context.Context, userID, queueKey string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.set(userTimeoutKey(userID), queueKey, ttl)
+	return nil
+}
+
+func (s *MemoryMatchStore) GetUserTimeout(ctx context.Context, userID string) (string, error) {
+	return s.getValue(userTimeoutKey(userID))
+}
+
+func (s *MemoryMatchStore) ClearUserTimeout(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, userTimeoutKey(userID))
+	return nil
+}
+
+func (s *MemoryMatchStore) getValue(key string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.get(key)
+	if !ok {
+		return "", ErrNotFound
+	}
+	return value, nil
+}
+
+func (s *MemoryMatchStore) ClaimPair(ctx context.Context, queueKeys []string, users []string, matchID string, matchData MatchData, ttl time.Duration) (bool, error) {
+	payload, err := json.Marshal(matchData)
+	if err != nil {
+		return false, err
+	}
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.queued(queueKeys[0], users[0]) || !s.queued(queueKeys[1], users[1]) {
+		return false, nil
+	}
+	if existing, ok := s.matches[matchID]; ok && !expired(existing.expiresAt) {
+		return false, ErrMatchIDInUse
+	}
+	s.zrem(queueKeys[0], users[0])
+	s.zrem(queueKeys[1], users[1])
+	s.matches[matchID] = memoryMatch{data: payload, expiresAt: expiryFrom(ttl)}
+	s.set(userMatchKey(users[0]), matchID, ttl)
+	s.set(userMatchKey(users[1]), matchID, ttl)
+	if matchData.AcceptBy > 0 {
+		s.pending[matchID] = matchData.AcceptBy
+	}
+	return true, nil
+}
+
+func (s *MemoryMatchStore) DropPair(ctx context.Context, queueKeys []string, users []string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.queued(queueKeys[0], users[0]) || !s.queued(queueKeys[1], users[1]) {
+		return false, nil
+	}
+	s.zrem(queueKeys[0], users[0])
+	s.zrem(queueKeys[1], users[1])
+	return true, nil
+}
+
+func (s *MemoryMatchStore) GetMatchData(ctx context.Context, matchID string) (*MatchData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadMatch(matchID)
+}
+
+func (s *MemoryMatchStore) GetMatch(ctx context.Context, matchID string) (*models.MatchResponse, error) {
+	matchData, err := s.GetMatchData(ctx, matchID)
+	if err != nil {
+		return nil, err
+	}
+	return matchData.Response(matchID), nil
+}
+
+// UpdateMatch applies update under the store lock, so unlike the Redis version it never retries.
+func (s *MemoryMatchStore) UpdateMatch(ctx context.Context, matchID string, update func(*MatchData) error) (*MatchData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	matchData, err := s.loadMatch(matchID)
+	if err != nil {
+		return nil, err
+	}
+	if err := update(matchData); err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(matchData)
+	if err != nil {
+		return nil, err
+	}
+	s.matches[matchID] = memoryMatch{data: payload, expiresAt: s.matches[matchID].expiresAt}
+	return matchData, nil
+}
+
+func (s *MemoryMatchStore) CancelMatch(ctx context.Context, matchID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.loadMatch(matchID); err != nil {
+		return false, nil
+	}
+	delete(s.matches, matchID)
+	return true, nil
+}
+
+func (s *MemoryMatchStore) RemovePendingAccept(ctx context.Context, matchID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pending, matchID)
+	return nil
+}
+
+func (s *MemoryMatchStore) GetOverduePendingAccepts(ctx context.Context, now time.Time) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var overdue []string
+	for matchID, deadline := range s.pending {
+		if deadline <= now.Unix() {
+			overdue = append(overdue, matchID)
+		}
+	}
+	sort.Slice(overdue, func(i, j int) bool {
+		if s.pending[overdue[i]] != s.pending[overdue[j]] {
+			return s.pending[overdue[i]] < s.pending[overdue[j]]
+		}
+		return overdue[i] < overdue[j]
+	})
+	return overdue, nil
+}
+
+func (s *MemoryMatchStore) AcquireLease(ctx context.Context, key, owner string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if holder, ok := s.get(key); ok && holder != owner {
+		return false, nil
+	}
+	s.set(key, owner, ttl)
+	return true, nil
+}
+
+func (s *MemoryMatchStore) ReleaseLease(ctx context.Context, key, owner string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if holder, ok := s.get(key); ok && holder == owner {
+		delete(s.values, key)
+	}
+	return nil
+}
+
+// PublishUserEvent delivers the event to every current subscriber of the user. Like Redis
+// pub/sub it does not wait for slow subscribers; events they cannot take are dropped.
+func (s *MemoryMatchStore) PublishUserEvent(ctx context.Context, userID string, event models.MatchEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, ch := range s.subscribers[userID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+	return nil
+}
+
+func (s *MemoryMatchStore) SubscribeUserEvents(ctx context.Context, userID string) (<-chan models.MatchEvent, func() error, error) {
+	events := make(chan models.MatchEvent, memoryEventBuffer)
+	s.mu.Lock()
+	s.subscribers[userID] = append(s.subscribers[userID], events)
+	s.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() error {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			subs := s.subscribers[userID]
+			for i, ch := range subs {
+				if ch == events {
+					s.subscribers[userID] = append(subs[:i], subs[i+1:]...)
+					break
+				}
+			}
+			if len(s.subscribers[userID]) == 0 {
+				delete(s.subscribers, userID)
+			}
+			close(events)
+		})
+		return nil
+	}
+	return events, unsubscribe, nil
+}
+
+// MemoryMatchHistoryRepository keeps match history in memory, for tests and local runs
+type MemoryMatchHistoryRepository struct {
+	mu      sync.RWMutex
+	records map[string]models.MatchHistoryRecord
+}
+
+func NewMemoryMatchHistoryRepository() *MemoryMatchHistoryRepository {
+	return &MemoryMatchHistoryRepository{records: make(map[string]models.MatchHistoryRecord)}
+}
+
+func (r *MemoryMatchHistoryRepository) Save(ctx context.Context, record models.MatchHistoryRecord) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records[record.MatchID] = record
+	return nil
+}
+
+func (r *MemoryMatchHistoryRepository) ListByUser(ctx context.Context, userID string, limit, offset int) ([]models.MatchHistoryRecord, int, error) {
+	r.mu.RLock()
+	var matches []models.MatchHistoryRecord
+	for _, record := range r.records {
+		if slices.Contains(record.UserIDs, userID) {
+			matches = append(matches, record)
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].MatchedAt.After(matches[j].MatchedAt)
+	})
+	total := len(matches)
+	if offset >= total {
+		return []models.MatchHistoryRecord{}, total, nil
+	}
+	end := min(offset+limit, total)
+	return matches[offset:end], total, nil
+}
+
+// MemoryAuditLogRepository keeps admin actions in memory, for tests
+type MemoryAuditLogRepository struct {
+	mu      sync.RWMutex
+	entries []models.AuditEntry
+}
+
+func NewMemoryAuditLogRepository() *MemoryAuditLogRepository {
+	return &MemoryAuditLogRepository{}
+}
+
+func (r *MemoryAuditLogRepository) Append(ctx context.Context, entry models.AuditEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.entries = append(r.entries, entry)
+	return nil
+}
+
+func (r *MemoryAuditLogRepository) Recent(ctx context.Context, limit int) ([]models.AuditEntry, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	n := min(limit, len(r.entries))
+	entries := make([]models.AuditEntry, 0, n)
+	for i := len(r.entries) - 1; i >= len(r.entries)-n; i-- {
+		entries = append(entries, r.entries[i])
+	}
+	return entries, nil
+}
+
+// MemoryUserLookup serves completed questions from a fixed map of user ID to question IDs
+type MemoryUserLookup struct {
+	Completed map[string][]string
+}
+
+func NewMemoryUserLookup() *MemoryUserLookup {
+	return &MemoryUserLookup{Completed: make(map[string][]string)}
+}
+
+func (l *MemoryUserLookup) GetCompletedQuestions(ctx context.Context, userID string) ([]string, error) {
+	return l.Completed[userID], nil
+}
+
+// MemoryQuestionLookup serves questions from a fixed list, filtered the way question-service does
+type MemoryQuestionLookup struct {
+	Questions []Question
+	// Err, when set, is returned from every lookup to simulate question-service being down
+	Err error
+}
+
+func NewMemoryQuestionLookup(questions ...Question) *MemoryQuestionLookup {
+	return &MemoryQuestionLookup{Questions: questions}
+}
+
+// GetQuestionsByDifficultyAndTag returns up to size questions with the given difficulty
+// (case-insensitive) tagged with tag by name or slug.
+func (l *MemoryQuestionLookup) GetQuestionsByDifficultyAndTag(ctx context.Context, difficulty, tag string, size int) ([]Question, error) {
+	if l.Err != nil {
+		return nil, l.Err
+	}
+	var questions []Question
+	for _, q := range l.Questions {
+		if len(questions) >= size {
+			break
+		}
+		if !strings.EqualFold(q.Difficulty, difficulty) {
+			continue
+		}
+		for _, t := range q.TopicTags {
+			if strings.EqualFold(t.Name, tag) || strings.EqualFold(t.Slug, tag) {
+				questions = append(questions, q)
+				break
+			}
+		}
+	}
+	return questions, nil
+}
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"matching-service/internal/constants"
+	"matching-service/internal/metrics"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// DefaultQuestionPoolSize matches the largest sample matching asks question-service for
+const DefaultQuestionPoolSize = 100
+
+// QuestionCacheOptions configures CachedQuestionRepository
+type QuestionCacheOptions struct {
+	// TTL is how long a cached pool is served before it is fetched again
+	TTL time.Duration
+	// PoolSize is how many questions are fetched and cached per difficulty and tag.
+	// Larger requests bypass the cache.
+	PoolSize int
+	// Metrics counts hits and misses; nil records nothing
+	Metrics *metrics.Metrics
+}
+
+// QuestionCacheStats counts cache lookups since startup
+type QuestionCacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+// CachedQuestionRepository serves candidate questions from pools cached in Redis per
+// difficulty and tag, falling back to question-service on a miss. For a single tag
+// question-service returns a random sample, so every lookup draws a fresh random sample
+// from the cached pool rather than always serving the same questions.
+type CachedQuestionRepository struct {
+	redis *redis.Client
+	inner QuestionLookup
+	opts  QuestionCacheOptions
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+
+	mu   sync.Mutex
+	seen map[questionPool]struct{} // Pools requested so far, kept warm by RunRefresh
+}
+
+type questionPool struct {
+	difficulty string
+	tag        string
+}
+
+func NewCachedQuestionRepository(redis *redis.Client, inner QuestionLookup, opts QuestionCacheOptions) *CachedQuestionRepository {
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = DefaultQuestionPoolSize
+	}
+	return &CachedQuestionRepository{
+		redis: redis,
+		inner: inner,
+		opts:  opts,
+		seen:  make(map[questionPool]struct{}),
+	}
+}
+
+// GetQuestionsByDifficultyAndTag returns up to size questions sampled at random from the
+// cached pool, fetching and caching the pool from question-service on a miss
+func (r *CachedQuestionRepository) GetQuestionsByDifficultyAndTag(ctx context.Context, difficulty, tag string, size int) ([]Question, error) {
+	if size > r.opts.PoolSize {
+		return r.inner.GetQuestionsByDifficultyAndTag(ctx, difficulty, tag, size)
+	}
+	pool := questionPool{difficulty: difficulty, tag: tag}
+	r.remember(pool)
+
+	questions, err := r.cached(ctx, pool)
+	if err == nil {
+		r.hits.Add(1)
+		r.opts.Metrics.ObserveQuestionCache(true)
+		return sampleQuestions(questions, size), nil
+	}
+	if err != ErrNotFound {
+		slog.WarnContext(ctx, "failed to read question cache", "key", pool.key(), "error", err)
+	}
+	r.misses.Add(1)
+	r.opts.Metrics.ObserveQuestionCache(false)
+
+	questions, err = r.fetch(ctx, pool)
+	if err != nil {
+		return nil, err
+	}
+	return sampleQuestions(questions, size), nil
+}
+
+// Stats returns the number of cache hits and misses so far
+func (r *CachedQuestionRepository) Stats() QuestionCacheStats {
+	return QuestionCacheStats{Hits: r.hits.Load(), Misses: r.misses.Load()}
+}
+
+// Refresh re-fetches every pool requested so far, so matches keep hitting the cache
+func (r *CachedQuestionRepository) Refresh(ctx context.Context) {
+	r.mu.Lock()
+	pools := make([]questionPool, 0, len(r.seen))
+	for pool := range r.seen {
+		pools = append(pools, pool)
+	}
+	r.mu.Unlock()
+
+	for _, pool := range pools {
+		if _, err := r.fetch(ctx, pool); err != nil {
+			slog.WarnContext(ctx, "failed to refresh question cache", "key", pool.key(), "error", err)
+		}
+	}
+}
+
+// RunRefresh refreshes the cached pools every interval until ctx is cancelled.
+// A non-positive interval disables refreshing; pools then expire after the TTL.
+func (r *CachedQuestionRepository) RunRefresh(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.Refresh(ctx)
+		}
+	}
+}
+
+func (r *CachedQuestionRepository) remember(pool questionPool) {
+	r.mu.Lock()
+	r.seen[pool] = struct{}{}
+	r.mu.Unlock()
+}
+
+func (r *CachedQuestionRepository) cached(ctx context.Context, pool questionPool) ([]Question, error) {
+	data, err := r.redis.Get(ctx, pool.key()).Bytes()
+	if err != nil {
+		return nil, notFound(err)
+	}
+	var questions []Question
+	if err := json.Unmarshal(data, &questions); err != nil {
+		return nil, err
+	}
+	return questions, nil
+}
+
+// fetch loads a full pool from question-service and caches it. Empty pools are cached too,
+// so a tag without questions does not reach question-service on every match.
+func (r *CachedQuestionRepository) fetch(ctx context.Context, pool questionPool) ([]Question, error) {
+	questions, err := r.inner.GetQuestionsByDifficultyAndTag(ctx, pool.difficulty, pool.tag, r.opts.PoolSize)
+	if err != nil {
+		return nil, err
+	}
+	if questions == nil {
+		questions = []Question{}
+	}
+	data, err := json.Marshal(questions)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.redis.Set(ctx, pool.key(), data, r.opts.TTL).Err(); err != nil {
+		slog.WarnContext(ctx, "failed to write question cache", "key", pool.key(), "error", err)
+	}
+	return questions, nil
+}
+
+func (p questionPool) key() string {
+	return strings.Join([]string{constants.QuestionCacheKeyPrefix, strings.ToLower(p.difficulty), strings.ToLower(p.tag)}, constants.QueueKeyDelimiter)
+}
+
+// sampleQuestions returns up to size questions in random order, leaving questions untouched
+func sampleQuestions(questions []Question, size int) []Question {
+	sample := append([]Question(nil), questions...)
+	rand.Shuffle(len(sample), func(i, j int) { sample[i], sample[j] = sample[j], sample[i] })
+	return sample[:min(size, len(sample))]
+}
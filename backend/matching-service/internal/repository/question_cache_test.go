@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// countingLookup counts the calls that reach question-service
+type countingLookup struct {
+	*MemoryQuestionLookup
+	calls int
+}
+
+func (l *countingLookup) GetQuestionsByDifficultyAndTag(ctx context.Context, difficulty, tag string, size int) ([]Question, error) {
+	l.calls++
+	return l.MemoryQuestionLookup.GetQuestionsByDifficultyAndTag(ctx, difficulty, tag, size)
+}
+
+func newTestQuestionCache(t *testing.T, opts QuestionCacheOptions) (*CachedQuestionRepository, *countingLookup, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	inner := &countingLookup{MemoryQuestionLookup: NewMemoryQuestionLookup(
+		Question{ID: "q1", Difficulty: "Easy", TopicTags: []TopicTag{{Name: "Array", Slug: "array"}}},
+		Question{ID: "q2", Difficulty: "Easy", TopicTags: []TopicTag{{Name: "Array", Slug: "array"}}},
+		Question{ID: "q3", Difficulty: "Easy", TopicTags: []TopicTag{{Name: "Array", Slug: "array"}}},
+	)}
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewCachedQuestionRepository(client, inner, opts), inner, mr
+}
+
+func TestCachedQuestionRepositoryServesPoolFromCache(t *testing.T) {
+	ctx := context.Background()
+	cache, inner, mr := newTestQuestionCache(t, QuestionCacheOptions{TTL: time.Minute, PoolSize: 3})
+
+	first, err := cache.GetQuestionsByDifficultyAndTag(ctx, "easy", "array", 2)
+	if err != nil {
+		t.Fatalf("GetQuestionsByDifficultyAndTag returned error: %v", err)
+	}
+	second, err := cache.GetQuestionsByDifficultyAndTag(ctx, "easy", "array", 3)
+	if err != nil {
+		t.Fatalf("GetQuestionsByDifficultyAndTag returned error: %v", err)
+	}
+	if len(first) != 2 || len(second) != 3 {
+		t.Fatalf("got %d and %d questions, want 2 and 3", len(first), len(second))
+	}
+	if inner.calls != 1 {
+		t.Fatalf("question-service called %d times, want 1", inner.calls)
+	}
+	if stats := cache.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Fatalf("stats = %+v, want 1 hit and 1 miss", stats)
+	}
+	if !mr.Exists("questions:easy:array") {
+		t.Fatal("pool was not cached under questions:easy:array")
+	}
+
+	// Once the pool expires the next lookup goes back to question-service
+	mr.FastForward(2 * time.Minute)
+	if _, err := cache.GetQuestionsByDifficultyAndTag(ctx, "easy", "array", 2); err != nil {
+		t.Fatalf("GetQuestionsByDifficultyAndTag returned error: %v", err)
+	}
+	if inner.calls != 2 {
+		t.Fatalf("question-service called %d times after expiry, want 2", inner.calls)
+	}
+}
+
+func TestCachedQuestionRepositorySamplesThePool(t *testing.T) {
+	ctx := context.Background()
+	cache, inner, _ := newTestQuestionCache(t, QuestionCacheOptions{TTL: time.Minute, PoolSize: 3})
+
+	// Each lookup has a one in three chance of each question, so 50 lookups all
+	// returning the same one would mean the pool is not being sampled
+	served := make(map[string]bool)
+	for i := 0; i < 50; i++ {
+		questions, err := cache.GetQuestionsByDifficultyAndTag(ctx, "easy", "array", 1)
+		if err != nil {
+			t.Fatalf("GetQuestionsByDifficultyAndTag returned error: %v", err)
+		}
+		if len(questions) != 1 {
+			t.Fatalf("got %d questions, want 1", len(questions))
+		}
+		served[questions[0].ID] = true
+	}
+	if len(served) < 2 {
+		t.Fatalf("served only %v across 50 lookups, want the pool sampled", served)
+	}
+	if inner.calls != 1 {
+		t.Fatalf("question-service called %d times, want 1", inner.calls)
+	}
+}
+
+func TestCachedQuestionRepositoryRefreshesRequestedPools(t *testing.T) {
+	ctx := context.Background()
+	cache, inner, mr := newTestQuestionCache(t, QuestionCacheOptions{TTL: time.Minute})
+
+	if _, err := cache.GetQuestionsByDifficultyAndTag(ctx, "easy", "array", 10); err != nil {
+		t.Fatalf("GetQuestionsByDifficultyAndTag returned error: %v", err)
+	}
+	mr.FastForward(50 * time.Second)
+	cache.Refresh(ctx)
+	if inner.calls != 2 {
+		t.Fatalf("question-service called %d times, want 2 after refresh", inner.calls)
+	}
+	if ttl := mr.TTL("questions:easy:array"); ttl != time.Minute {
+		t.Fatalf("TTL after refresh = %v, want %v", ttl, time.Minute)
+	}
+}
+
+func TestCachedQuestionRepositoryDoesNotCacheFailures(t *testing.T) {
+	ctx := context.Background()
+	cache, inner, mr := newTestQuestionCache(t, QuestionCacheOptions{TTL: time.Minute})
+	inner.Err = errors.New("question-service unavailable")
+
+	if _, err := cache.GetQuestionsByDifficultyAndTag(ctx, "easy", "array", 10); err == nil {
+		t.Fatal("expected the question-service error")
+	}
+	if mr.Exists("questions:easy:array") {
+		t.Fatal("a failed fetch was cached")
+	}
+}
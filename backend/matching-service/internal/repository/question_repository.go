@@ -7,22 +7,22 @@ import (
 	"net/http"
 	"net/url"
 	"strconv"
-	"time"
+	"strings"
 )
 
 type QuestionRepository struct {
-	baseURL    string
-	httpClient *http.Client
+	baseURL string
+	client  *OutboundClient
 }
 
 type Question struct {
-	ID               string      `json:"id"`
-	Title            string      `json:"title"`
-	TitleSlug        string      `json:"titleSlug"`
-	Difficulty       string      `json:"difficulty"`
-	Question         string      `json:"question"`
-	ExampleTestcases string      `json:"exampleTestcases"`
-	TopicTags        []TopicTag  `json:"topicTags"`
+	ID               string     `json:"id"`
+	Title            string     `json:"title"`
+	TitleSlug        string     `json:"titleSlug"`
+	Difficulty       string     `json:"difficulty"`
+	Question         string     `json:"question"`
+	ExampleTestcases string     `json:"exampleTestcases"`
+	TopicTags        []TopicTag `json:"topicTags"`
 }
 
 type TopicTag struct {
@@ -30,50 +30,76 @@ type TopicTag struct {
 	Slug string `json:"slug"`
 }
 
-func NewQuestionRepository(baseURL string) *QuestionRepository {
-	return &QuestionRepository{
-		baseURL: baseURL,
-		httpClient: &http.Client{
-			Timeout: 15 * time.Second,
-		},
-	}
+func NewQuestionRepository(baseURL string, client *OutboundClient) *QuestionRepository {
+	return &QuestionRepository{baseURL: baseURL, client: client}
+}
+
+// Breaker returns the circuit breaker guarding calls to the service
+func (r *QuestionRepository) Breaker() *CircuitBreaker {
+	return r.client.Breaker()
+}
+
+// Ping checks that question-service is reachable through its topics endpoint
+func (r *QuestionRepository) Ping(ctx context.Context) error {
+	return r.client.Probe(ctx, r.topicsURL())
 }
 
 // GetQuestionsByDifficultyAndTag fetches questions matching the given difficulty and tag with the specified sample size
 func (r *QuestionRepository) GetQuestionsByDifficultyAndTag(ctx context.Context, difficulty, tag string, size int) ([]Question, error) {
 	// Build query parameters
 	params := url.Values{}
-    params.Add("difficulty", difficulty)
-    params.Add("tag", tag)
-    params.Add("size", strconv.Itoa(size))
-    
-    queryStr := params.Encode()
-    url := fmt.Sprintf("%s/?%s", r.baseURL, queryStr)
-	fmt.Printf("Calling question service URL: %s\n", url)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
+	params.Add("difficulty", difficulty)
+	params.Add("tag", tag)
+	params.Add("size", strconv.Itoa(size))
 
-	resp, err := r.httpClient.Do(req)
+	queryStr := params.Encode()
+	url := fmt.Sprintf("%s/?%s", r.baseURL, queryStr)
+
+	resp, err := r.client.Get(ctx, url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to call question service: %w", err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode == http.StatusBadRequest {
-		return nil, fmt.Errorf("invalid parameters: difficulty=%s, tag=%s", difficulty, tag)
+	// 429 and 5xx mean question-service is struggling; any other failure is an answer
+	// about this request, which asking again will not change
+	switch {
+	case resp.StatusCode == http.StatusBadRequest:
+		return nil, fmt.Errorf("%w: invalid parameters: difficulty=%s, tag=%s", ErrUnusableResponse, difficulty, tag)
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return nil, fmt.Errorf("question service returned status %d", resp.StatusCode)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%w: question service returned status %d", ErrUnusableResponse, resp.StatusCode)
+	}
+
+	var questions []Question
+	if err := json.NewDecoder(resp.Body).Decode(&questions); err != nil {
+		return nil, fmt.Errorf("%w: failed to decode response: %v", ErrUnusableResponse, err)
 	}
 
+	return questions, nil
+}
+
+// GetTopicTags fetches every topic tag question-service knows about. The topics endpoint
+// sits next to the questions endpoint, so a base URL ending in /questions maps to /topics.
+func (r *QuestionRepository) GetTopicTags(ctx context.Context) ([]TopicTag, error) {
+	resp, err := r.client.Get(ctx, r.topicsURL())
+	if err != nil {
+		return nil, fmt.Errorf("failed to call question service: %w", err)
+	}
+	defer resp.Body.Close()
+
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("question service returned status %d", resp.StatusCode)
 	}
 
-	var questions []Question
-	if err := json.NewDecoder(resp.Body).Decode(&questions); err != nil {
+	var tags []TopicTag
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
+	return tags, nil
+}
 
-	return questions, nil
+func (r *QuestionRepository) topicsURL() string {
+	return strings.TrimSuffix(strings.TrimRight(r.baseURL, "/"), "/questions") + "/topics"
 }
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Bucket is a token bucket holding up to Capacity tokens and regaining one every Refill
+type Bucket struct {
+	Key      string
+	Capacity int
+	Refill   time.Duration
+}
+
+// takeTokensScript refills every bucket for the time elapsed since it was last used, then
+// takes one token from each only if all of them have one, so a request refused by one
+// bucket does not use up the others. A replica whose clock is behind never rewinds a bucket.
+// Buckets expire once they would be full again.
+// KEYS: bucket keys  ARGV: nowMillis, then capacity and refillMillis for each key
+// Returns the milliseconds until every bucket has a token, 0 when the tokens were taken.
+var takeTokensScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local tokens = {}
+local stamps = {}
+local wait = 0
+for i, key in ipairs(KEYS) do
+	local capacity = tonumber(ARGV[i * 2])
+	local refill = tonumber(ARGV[i * 2 + 1])
+	local state = redis.call("HMGET", key, "tokens", "ts")
+	local available = tonumber(state[1])
+	local ts = tonumber(state[2])
+	if available == nil then
+		available = capacity
+		ts = now
+	elseif now > ts then
+		available = math.min(capacity, available + (now - ts) / refill)
+		ts = now
+	end
+	tokens[i] = available
+	stamps[i] = ts
+	if available < 1 then
+		wait = math.max(wait, math.ceil((1 - available) * refill))
+	end
+end
+for i, key in ipairs(KEYS) do
+	local capacity = tonumber(ARGV[i * 2])
+	local refill = tonumber(ARGV[i * 2 + 1])
+	local available = tokens[i]
+	if wait == 0 then
+		available = available - 1
+	end
+	redis.call("HSET", key, "tokens", tostring(available), "ts", stamps[i])
+	redis.call("PEXPIRE", key, math.ceil((capacity - available) * refill) + 1)
+end
+return wait
+`)
+
+type RateLimitRepository struct {
+	redis *redis.Client
+}
+
+func NewRateLimitRepository(redis *redis.Client) *RateLimitRepository {
+	return &RateLimitRepository{redis: redis}
+}
+
+// Take takes a token from every bucket at now, or from none of them. When any bucket is
+// empty it returns how long until all of them have a token again.
+func (r *RateLimitRepository) Take(ctx context.Context, buckets []Bucket, now time.Time) (retryAfter time.Duration, err error) {
+	if len(buckets) == 0 {
+		return 0, nil
+	}
+	keys := make([]string, len(buckets))
+	args := []interface{}{now.UnixMilli()}
+	for i, bucket := range buckets {
+		keys[i] = bucket.Key
+		args = append(args, bucket.Capacity, bucket.Refill.Milliseconds())
+	}
+	wait, err := takeTokensScript.Run(ctx, r.redis, keys, args...).Int64()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(wait) * time.Millisecond, nil
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func newTestRateLimitRepository(t *testing.T) (*RateLimitRepository, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRateLimitRepository(client), mr
+}
+
+func TestRateLimitRepositoryRefillsOverTime(t *testing.T) {
+	ctx := context.Background()
+	repo, _ := newTestRateLimitRepository(t)
+	buckets := []Bucket{{Key: "ratelimit:test:user:u1", Capacity: 2, Refill: 10 * time.Second}}
+	now := time.Unix(1000, 0)
+
+	for i := 0; i < 2; i++ {
+		if wait, err := repo.Take(ctx, buckets, now); err != nil || wait != 0 {
+			t.Fatalf("take %d = %v, %v; want the burst allowed", i, wait, err)
+		}
+	}
+	wait, err := repo.Take(ctx, buckets, now)
+	if err != nil {
+		t.Fatalf("Take returned error: %v", err)
+	}
+	if wait != 10*time.Second {
+		t.Fatalf("empty bucket wait = %v, want 10s", wait)
+	}
+
+	if wait, _ := repo.Take(ctx, buckets, now.Add(4*time.Second)); wait != 6*time.Second {
+		t.Fatalf("wait after 4s = %v, want 6s", wait)
+	}
+	if wait, _ := repo.Take(ctx, buckets, now.Add(10*time.Second)); wait != 0 {
+		t.Fatalf("wait after a full refill = %v, want the token taken", wait)
+	}
+}
+
+func TestRateLimitRepositoryTakesFromAllBucketsOrNone(t *testing.T) {
+	ctx := context.Background()
+	repo, mr := newTestRateLimitRepository(t)
+	user := Bucket{Key: "ratelimit:test:user:u1", Capacity: 5, Refill: time.Second}
+	ip := Bucket{Key: "ratelimit:test:ip:10.0.0.1", Capacity: 1, Refill: time.Minute}
+	now := time.Unix(1000, 0)
+
+	if wait, err := repo.Take(ctx, []Bucket{user, ip}, now); err != nil || wait != 0 {
+		t.Fatalf("first take = %v, %v; want allowed", wait, err)
+	}
+	if wait, _ := repo.Take(ctx, []Bucket{user, ip}, now); wait != time.Minute {
+		t.Fatalf("wait = %v, want the IP bucket's minute", wait)
+	}
+	if tokens := mr.HGet(user.Key, "tokens"); tokens != "4" {
+		t.Fatalf("user bucket has %s tokens, want 4: a refused request must not use it up", tokens)
+	}
+	if ttl := mr.TTL(user.Key); ttl <= 0 || ttl > 2*time.Second {
+		t.Fatalf("user bucket TTL = %v, want it to expire once refilled", ttl)
+	}
+}
@@ -5,12 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"time"
 )
 
 type UserRepository struct {
-	baseURL    string
-	httpClient *http.Client
+	baseURL string
+	client  *OutboundClient
 }
 
 type CompletedQuestionsResponse struct {
@@ -18,25 +17,25 @@ type CompletedQuestionsResponse struct {
 	Data    []string `json:"data"`
 }
 
-func NewUserRepository(baseURL string) *UserRepository {
-	return &UserRepository{
-		baseURL: baseURL,
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-	}
+func NewUserRepository(baseURL string, client *OutboundClient) *UserRepository {
+	return &UserRepository{baseURL: baseURL, client: client}
+}
+
+// Breaker returns the circuit breaker guarding calls to the service
+func (r *UserRepository) Breaker() *CircuitBreaker {
+	return r.client.Breaker()
+}
+
+// Ping checks that user-service is reachable
+func (r *UserRepository) Ping(ctx context.Context) error {
+	return r.client.Probe(ctx, r.baseURL+"/")
 }
 
 // GetCompletedQuestions fetches the list of completed question IDs for a given user
 func (r *UserRepository) GetCompletedQuestions(ctx context.Context, userID string) ([]string, error) {
 	url := fmt.Sprintf("%s/users/%s/completed-questions", r.baseURL, userID)
 
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	resp, err := r.httpClient.Do(req)
+	resp, err := r.client.Get(ctx, url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to call user service: %w", err)
 	}
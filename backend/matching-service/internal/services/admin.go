@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"matching-service/internal/models"
+	"matching-service/internal/repository"
+)
+
+var (
+	ErrUserNotQueued      = errors.New("user is not waiting in a queue")
+	ErrInvalidQueue       = errors.New("malformed queue key")
+	ErrInvalidPair        = errors.New("a pair needs two different users")
+	ErrNoSuitableQuestion = errors.New("no suitable question for this pair")
+	ErrPairNoLongerQueued = errors.New("one of the users left their queue")
+)
+
+// QueueSummary returns the size of every queue
+func (s *MatchingService) QueueSummary(ctx context.Context, actor string) (*models.QueueSummary, error) {
+	queues, err := s.repo.GetAllQueues(ctx)
+	s.recordAudit(ctx, models.AuditEntry{Actor: actor, Action: models.AuditQueueSummary}, err)
+	if err != nil {
+		return nil, err
+	}
+	summary := &models.QueueSummary{Queues: []models.QueueInfo{}}
+	for _, q := range queues {
+		if q.Size == 0 {
+			continue
+		}
+		summary.Queues = append(summary.Queues, q)
+		summary.TotalWaiting += q.Size
+	}
+	return summary, nil
+}
+
+// ListQueueUsers returns every waiting user on behalf of an admin
+func (s *MatchingService) ListQueueUsers(ctx context.Context, actor string) ([]models.QueueUser, error) {
+	users, err := s.GetQueueUsers(ctx)
+	s.recordAudit(ctx, models.AuditEntry{Actor: actor, Action: models.AuditListQueue}, err)
+	return users, err
+}
+
+// RemoveQueuedUser takes a user out of their queue and tells them they were removed
+func (s *MatchingService) RemoveQueuedUser(ctx context.Context, actor, userID string) (string, error) {
+	entry := models.AuditEntry{Actor: actor, Action: models.AuditRemoveUser, UserIDs: []string{userID}}
+	queueKey, err := s.repo.GetUserQueue(ctx, userID)
+	if errors.Is(err, repository.ErrNotFound) || (err == nil && queueKey == "") {
+		err = ErrUserNotQueued
+	}
+	if err == nil {
+		entry.Queue = queueKey
+		err = s.removeQueuedUser(ctx, queueKey, userID)
+	}
+	s.recordAudit(ctx, entry, err)
+	return queueKey, err
+}
+
+// DrainQueue removes every user from the queue
+func (s *MatchingService) DrainQueue(ctx context.Context, actor, queueKey string) ([]string, error) {
+	removed, err := s.drainQueue(ctx, queueKey)
+	s.recordAudit(ctx, models.AuditEntry{Actor: actor, Action: models.AuditDrainQueue, Queue: queueKey, UserIDs: removed}, err)
+	return removed, err
+}
+
+func (s *MatchingService) drainQueue(ctx context.Context, queueKey string) ([]string, error) {
+	if _, _, ok := parseQueueKey(queueKey); !ok {
+		return nil, ErrInvalidQueue
+	}
+	queueUsers, err := s.repo.GetAllQueueUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	removed := []string{}
+	for _, userID := range queueUsers[queueKey] {
+		if err := s.removeQueuedUser(ctx, queueKey, userID); err != nil {
+			return removed, err
+		}
+		removed = append(removed, userID)
+	}
+	return removed, nil
+}
+
+func (s *MatchingService) removeQueuedUser(ctx context.Context, queueKey, userID string) error {
+	if err := s.repo.RemoveFromQueue(ctx, queueKey, userID); err != nil {
+		return err
+	}
+	if current, err := s.repo.GetUserQueue(ctx, userID); err == nil && current == queueKey {
+		_ = s.repo.SaveUserQueue(ctx, userID, "", 0)
+	}
+	s.publishEvent(ctx, userID, models.MatchEvent{Type: models.EventCancelled, Queue: queueKey, Reason: models.ReasonRemovedByAdmin})
+	s.publishQueuePositions(ctx, queueKey)
+	return nil
+}
+
+// ForcePair matches two waiting users regardless of their criteria. The match uses their
+// shared topics (or the first user's if they share none) and the first user's difficulty.
+// Both users stay queued if no question can be found.
+func (s *MatchingService) ForcePair(ctx context.Context, actor string, userIDs []string) (*models.MatchResponse, error) {
+	res, err := s.forcePair(ctx, userIDs)
+	entry := models.AuditEntry{Actor: actor, Action: models.AuditForcePair, UserIDs: userIDs}
+	if res != nil {
+		entry.MatchID = res.MatchID
+	}
+	s.recordAudit(ctx, entry, err)
+	return res, err
+}
+
+func (s *MatchingService) forcePair(ctx context.Context, userIDs []string) (*models.MatchResponse, error) {
+	if len(userIDs) != 2 || userIDs[0] == userIDs[1] {
+		return nil, ErrInvalidPair
+	}
+	users := make([]queuedUser, len(userIDs))
+	for i, userID := range userIDs {
+		queueKey, err := s.repo.GetUserQueue(ctx, userID)
+		if errors.Is(err, repository.ErrNotFound) || (err == nil && queueKey == "") {
+			return nil, ErrUserNotQueued
+		}
+		if err != nil {
+			return nil, err
+		}
+		difficulty, topics, ok := parseQueueKey(queueKey)
+		if !ok {
+			return nil, ErrInvalidQueue
+		}
+		users[i] = queuedUser{userID: userID, queueKey: queueKey, difficulty: difficulty, topics: topics}
+	}
+
+	a, b := users[0], users[1]
+	p := &pairing{
+		users:      userIDs,
+		queueKeys:  []string{a.queueKey, b.queueKey},
+		topics:     sharedTopics(a.topics, b.topics),
+		difficulty: a.difficulty,
+	}
+	if len(p.topics) != len(a.topics) || len(p.topics) != len(b.topics) {
+		p.relaxed = append(p.relaxed, RelaxedTopics)
+	}
+	if len(p.topics) == 0 {
+		p.topics = a.topics
+	}
+	if a.difficulty != b.difficulty {
+		p.relaxed = append(p.relaxed, RelaxedDifficulty)
+	}
+
+	choice, err := s.selectQuestion(ctx, a.userID, b.userID, p.topics, p.difficulty)
+	if err != nil {
+		return nil, err
+	}
+	res, claimed, err := s.claimMatch(ctx, p, choice)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrPairNoLongerQueued
+	}
+	return res, nil
+}
+
+// AdminCancelMatch cancels any match and releases both users
+func (s *MatchingService) AdminCancelMatch(ctx context.Context, actor, matchID string) error {
+	_, err := s.repo.GetMatchData(ctx, matchID)
+	if err == nil {
+		err = s.cancelMatch(ctx, matchID, "")
+	}
+	err = matchError(err)
+	s.recordAudit(ctx, models.AuditEntry{Actor: actor, Action: models.AuditCancelMatch, MatchID: matchID}, err)
+	return err
+}
+
+// RecentAuditEntries returns up to limit admin actions, newest first
+func (s *MatchingService) RecentAuditEntries(ctx context.Context, limit int) ([]models.AuditEntry, error) {
+	if s.audit == nil {
+		return []models.AuditEntry{}, nil
+	}
+	return s.audit.Recent(ctx, limit)
+}
+
+// recordAudit logs the admin action and appends it to the audit log with its outcome
+func (s *MatchingService) recordAudit(ctx context.Context, entry models.AuditEntry, actionErr error) {
+	entry.Time = time.Now()
+	entry.Success = actionErr == nil
+	if actionErr != nil {
+		entry.Error = actionErr.Error()
+	}
+	slog.InfoContext(ctx, "audit", "actor", entry.Actor, "action", entry.Action, "user_ids", entry.UserIDs,
+		"queue", entry.Queue, "match_id", entry.MatchID, "success", entry.Success)
+	if s.audit == nil {
+		return
+	}
+	if err := s.audit.Append(ctx, entry); err != nil {
+		slog.ErrorContext(ctx, "failed to write audit entry", "action", entry.Action, "actor", entry.Actor, "error", err)
+	}
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"matching-service/internal/models"
+	"matching-service/internal/repository"
+)
+
+func newAdminTestService(t *testing.T) (*MatchingService, fakes, *repository.MemoryAuditLogRepository) {
+	t.Helper()
+	audit := repository.NewMemoryAuditLogRepository()
+	opts := testOptions
+	opts.Audit = audit
+	service, f := newFakeService(t, opts)
+	return service, f, audit
+}
+
+func TestForcePair(t *testing.T) {
+	tests := []struct {
+		name        string
+		setup       func(t *testing.T, f fakes)
+		userIDs     []string
+		wantErr     error
+		wantTopics  []string
+		wantRelaxed []string
+	}{
+		{
+			name: "different criteria",
+			setup: func(t *testing.T, f fakes) {
+				f.queueUser(t, "u1", []string{"array"}, "easy", time.Now())
+				f.queueUser(t, "u2", []string{"array", "graph"}, "medium", time.Now())
+			},
+			userIDs:     []string{"u1", "u2"},
+			wantTopics:  []string{"array"},
+			wantRelaxed: []string{RelaxedTopics, RelaxedDifficulty},
+		},
+		{
+			name: "no shared topics uses the first user's",
+			setup: func(t *testing.T, f fakes) {
+				f.queueUser(t, "u1", []string{"array"}, "easy", time.Now())
+				f.queueUser(t, "u2", []string{"graph"}, "easy", time.Now())
+			},
+			userIDs:     []string{"u1", "u2"},
+			wantTopics:  []string{"array"},
+			wantRelaxed: []string{RelaxedTopics},
+		},
+		{
+			name: "user not queued",
+			setup: func(t *testing.T, f fakes) {
+				f.queueUser(t, "u1", []string{"array"}, "easy", time.Now())
+			},
+			userIDs: []string{"u1", "u2"},
+			wantErr: ErrUserNotQueued,
+		},
+		{
+			name:    "same user twice",
+			userIDs: []string{"u1", "u1"},
+			wantErr: ErrInvalidPair,
+		},
+		{
+			name: "no question keeps both queued",
+			setup: func(t *testing.T, f fakes) {
+				f.queueUser(t, "u1", []string{"graph"}, "hard", time.Now())
+				f.queueUser(t, "u2", []string{"graph"}, "hard", time.Now())
+			},
+			userIDs: []string{"u1", "u2"},
+			wantErr: ErrNoSuitableQuestion,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, f, audit := newAdminTestService(t)
+			ctx := context.Background()
+			if tt.setup != nil {
+				tt.setup(t, f)
+			}
+
+			res, err := service.ForcePair(ctx, "admin", tt.userIDs)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("ForcePair error = %v, want %v", err, tt.wantErr)
+				}
+				for _, userID := range tt.userIDs {
+					if _, err := f.store.GetUserMatch(ctx, userID); err == nil {
+						t.Errorf("%s was matched despite the error", userID)
+					}
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("ForcePair returned error: %v", err)
+				}
+				if !equalStrings(res.Topics, tt.wantTopics) || !equalStrings(res.Relaxed, tt.wantRelaxed) {
+					t.Fatalf("topics = %v relaxed = %v, want %v %v", res.Topics, res.Relaxed, tt.wantTopics, tt.wantRelaxed)
+				}
+			}
+
+			entries, _ := audit.Recent(ctx, 10)
+			if len(entries) != 1 || entries[0].Action != models.AuditForcePair || entries[0].Actor != "admin" || entries[0].Success != (tt.wantErr == nil) {
+				t.Fatalf("audit log = %+v, want one force_pair entry by admin", entries)
+			}
+		})
+	}
+}
+
+func TestDrainQueueRemovesEveryoneAndAudits(t *testing.T) {
+	service, f, audit := newAdminTestService(t)
+	ctx := context.Background()
+
+	queueKey := f.queueUser(t, "u1", []string{"graph"}, "hard", time.Now())
+	f.queueUser(t, "u2", []string{"graph"}, "hard", time.Now())
+	otherKey := f.queueUser(t, "u3", []string{"array"}, "easy", time.Now())
+
+	removed, err := service.DrainQueue(ctx, "admin", queueKey)
+	if err != nil {
+		t.Fatalf("DrainQueue returned error: %v", err)
+	}
+	if !equalStrings(removed, []string{"u1", "u2"}) {
+		t.Fatalf("removed = %v, want [u1 u2]", removed)
+	}
+	queues, _ := f.store.GetAllQueueUsers(ctx)
+	if len(queues[queueKey]) != 0 || !equalStrings(queues[otherKey], []string{"u3"}) {
+		t.Fatalf("queues = %v, want only %s drained", queues, queueKey)
+	}
+	if status, _, _ := service.CheckUserStatus(ctx, "u1"); status != UserStatusNone {
+		t.Fatalf("drained user status = %d, want %d", status, UserStatusNone)
+	}
+
+	if _, err := service.DrainQueue(ctx, "admin", "not-a-queue"); !errors.Is(err, ErrInvalidQueue) {
+		t.Fatalf("DrainQueue on a bad key = %v, want ErrInvalidQueue", err)
+	}
+	entries, _ := audit.Recent(ctx, 10)
+	if len(entries) != 2 || entries[0].Success || !entries[1].Success || !equalStrings(entries[1].UserIDs, []string{"u1", "u2"}) {
+		t.Fatalf("audit log = %+v, want a failed drain after a successful one", entries)
+	}
+}
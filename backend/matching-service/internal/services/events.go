@@ -0,0 +1,92 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+
+	"matching-service/internal/models"
+)
+
+// SubscribeUserEvents streams match events for a user published by any replica.
+// Call the returned function to end the subscription.
+func (s *MatchingService) SubscribeUserEvents(ctx context.Context, userID string) (<-chan models.MatchEvent, func() error, error) {
+	return s.repo.SubscribeUserEvents(ctx, userID)
+}
+
+// CurrentUserEvent describes the user's current state as an event, or nil if the
+// user is not waiting, paired or timed out.
+func (s *MatchingService) CurrentUserEvent(ctx context.Context, userID string) (*models.MatchEvent, error) {
+	status, details, err := s.CheckUserStatus(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	switch status {
+	case UserStatusMatched:
+		matchID, _ := details["matchId"].(string)
+		return s.matchedEvent(ctx, userID, matchID, models.EventMatched), nil
+	case UserStatusPending:
+		matchID, _ := details["matchId"].(string)
+		event := s.matchedEvent(ctx, userID, matchID, models.EventPendingAccept)
+		event.AcceptBy, _ = details["acceptBy"].(int64)
+		return event, nil
+	case UserStatusTimedOut:
+		queueKey, _ := details["queue"].(string)
+		return &models.MatchEvent{Type: models.EventTimeout, Queue: queueKey}, nil
+	case UserStatusWaiting:
+		event := &models.MatchEvent{Type: models.EventWaiting}
+		event.Queue, _ = details["queue"].(string)
+		if position, ok := details["position"].(int64); ok {
+			event.Position = &position
+		}
+		return event, nil
+	}
+	return nil, nil
+}
+
+// matchedEvent builds a match event for userID, filling in the partner and question when the match is still stored
+func (s *MatchingService) matchedEvent(ctx context.Context, userID, matchID, eventType string) *models.MatchEvent {
+	event := &models.MatchEvent{Type: eventType, MatchID: matchID}
+	match, err := s.repo.GetMatch(ctx, matchID)
+	if err != nil {
+		return event
+	}
+	event.QuestionID = match.QuestionID
+	for _, id := range match.UserIDs {
+		if id != userID {
+			event.PartnerID = id
+			break
+		}
+	}
+	return event
+}
+
+// publishWaiting notifies a user of their current queue position, with an optional reason
+// when they were put back in the queue
+func (s *MatchingService) publishWaiting(ctx context.Context, userID, queueKey, reason string) {
+	event := models.MatchEvent{Type: models.EventWaiting, Queue: queueKey, Reason: reason}
+	if rank, err := s.repo.GetUserQueueRank(ctx, queueKey, userID); err == nil && rank >= 0 {
+		event.Position = &rank
+	}
+	s.publishEvent(ctx, userID, event)
+}
+
+// publishQueuePositions tells everyone still waiting in a queue their position after users
+// left it or were put back, so connected clients see the change without polling
+func (s *MatchingService) publishQueuePositions(ctx context.Context, queueKey string) {
+	users, err := s.repo.GetQueueMembers(ctx, queueKey)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to list queue for position updates", "queue", queueKey, "error", err)
+		return
+	}
+	for i, userID := range users {
+		position := int64(i)
+		s.publishEvent(ctx, userID, models.MatchEvent{Type: models.EventWaiting, Queue: queueKey, Position: &position})
+	}
+}
+
+// publishEvent delivers an event on a best-effort basis; clients still fall back to polling
+func (s *MatchingService) publishEvent(ctx context.Context, userID string, event models.MatchEvent) {
+	if err := s.repo.PublishUserEvent(ctx, userID, event); err != nil {
+		slog.ErrorContext(ctx, "failed to publish event", "event", event.Type, "user_id", userID, "error", err)
+	}
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"matching-service/internal/models"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// subscribe listens for userID's events on service until the test ends
+func subscribe(t *testing.T, service *MatchingService, userID string) <-chan models.MatchEvent {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	events, unsubscribe, err := service.SubscribeUserEvents(ctx, userID)
+	if err != nil {
+		t.Fatalf("SubscribeUserEvents: %v", err)
+	}
+	t.Cleanup(func() {
+		cancel()
+		_ = unsubscribe()
+	})
+	return events
+}
+
+// nextEvent waits for the next event of the given type, skipping any others
+func nextEvent(t *testing.T, events <-chan models.MatchEvent, eventType string) models.MatchEvent {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				t.Fatalf("subscription closed before a %s event", eventType)
+			}
+			if event.Type == eventType {
+				return event
+			}
+		case <-timeout:
+			t.Fatalf("no %s event delivered", eventType)
+		}
+	}
+}
+
+func TestMatchEventReachesSubscriberOnAnotherReplica(t *testing.T) {
+	mr := miniredis.RunT(t)
+	replicaA := newTestReplica(t, mr, testOptions)
+	replicaB := newTestReplica(t, mr, testOptions)
+	ctx := context.Background()
+
+	// u1 is connected to replica B while u2's request lands on replica A
+	events := subscribe(t, replicaB, "u1")
+	if _, err := replicaB.RequestMatch(ctx, models.MatchRequest{Topics: []string{"array"}, Difficulty: "easy", UserID: "u1"}); err != nil {
+		t.Fatalf("RequestMatch returned error: %v", err)
+	}
+	res, err := replicaA.RequestMatch(ctx, models.MatchRequest{Topics: []string{"array"}, Difficulty: "easy", UserID: "u2"})
+	if err != nil || res.Status != "matched" {
+		t.Fatalf("RequestMatch = %+v, %v; want matched", res, err)
+	}
+
+	event := nextEvent(t, events, models.EventMatched)
+	if event.MatchID != res.MatchID || event.PartnerID != "u2" || event.QuestionID != "q1" {
+		t.Fatalf("event = %+v, want the match with u2 on q1", event)
+	}
+}
+
+func TestQueueChangesPushPositions(t *testing.T) {
+	mr := miniredis.RunT(t)
+	replicaA := newTestReplica(t, mr, testOptions)
+	replicaB := newTestReplica(t, mr, testOptions)
+	ctx := context.Background()
+
+	_, queueKey := buildQueueKey([]string{"array"}, "easy")
+	seedQueue(t, mr, queueKey, "u1", time.Now().Add(-2*time.Second))
+	seedQueue(t, mr, queueKey, "u2", time.Now().Add(-time.Second))
+	if err := replicaA.repo.SaveUserQueue(ctx, "u1", queueKey, time.Hour); err != nil {
+		t.Fatalf("seeding queue mapping: %v", err)
+	}
+
+	events := subscribe(t, replicaB, "u2")
+	if _, _, err := replicaA.CancelByUser(ctx, "u1"); err != nil {
+		t.Fatalf("CancelByUser returned error: %v", err)
+	}
+
+	event := nextEvent(t, events, models.EventWaiting)
+	if event.Queue != queueKey || event.Position == nil || *event.Position != 0 {
+		t.Fatalf("event = %+v, want u2 moved to the front of %s", event, queueKey)
+	}
+}
@@ -0,0 +1,539 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"matching-service/internal/metrics"
+	"matching-service/internal/models"
+	"matching-service/internal/repository"
+)
+
+// These tests run the service against the in-memory fakes, so they need neither Redis nor
+// the user and question services.
+
+type fakes struct {
+	store     *repository.MemoryMatchStore
+	users     *repository.MemoryUserLookup
+	questions *repository.MemoryQuestionLookup
+}
+
+var fakeQuestions = []repository.Question{
+	{ID: "q1", Title: "Two Sum", Difficulty: "Easy", TopicTags: []repository.TopicTag{{Name: "Array", Slug: "array"}}},
+	{ID: "q2", Title: "Contains Duplicate", Difficulty: "Easy", TopicTags: []repository.TopicTag{{Name: "Array", Slug: "array"}}},
+	{ID: "q3", Title: "Course Schedule", Difficulty: "Medium", TopicTags: []repository.TopicTag{{Name: "Graph", Slug: "graph"}}},
+	{ID: "q4", Title: "House Robber", Difficulty: "Medium", TopicTags: []repository.TopicTag{{Name: "Dynamic Programming", Slug: "dynamic-programming"}}},
+	{ID: "q5", Title: "Cheapest Flights Within K Stops", Difficulty: "Medium", TopicTags: []repository.TopicTag{
+		{Name: "Graph", Slug: "graph"}, {Name: "Dynamic Programming", Slug: "dynamic-programming"},
+	}},
+}
+
+func newFakeService(t *testing.T, opts Options) (*MatchingService, fakes) {
+	t.Helper()
+	history := repository.NewMemoryMatchHistoryRepository()
+	f := fakes{
+		store:     repository.NewMemoryMatchStore(),
+		users:     repository.NewMemoryUserLookup(),
+		questions: repository.NewMemoryQuestionLookup(fakeQuestions...),
+	}
+	return NewMatchingService(f.store, f.users, f.questions, history, opts), f
+}
+
+// queueUser puts a live user into the queue for topics/difficulty as if they enqueued at enqueuedAt
+func (f fakes) queueUser(t *testing.T, userID string, topics []string, difficulty string, enqueuedAt time.Time) string {
+	t.Helper()
+	ctx := context.Background()
+	_, queueKey := buildQueueKey(topics, difficulty)
+	if err := f.store.Requeue(ctx, queueKey, userID, enqueuedAt, time.Hour); err != nil {
+		t.Fatalf("queueing %s: %v", userID, err)
+	}
+	if err := f.store.RefreshUserAlive(ctx, userID, time.Hour); err != nil {
+		t.Fatalf("marking %s alive: %v", userID, err)
+	}
+	return queueKey
+}
+
+func TestRequestMatchWithFakes(t *testing.T) {
+	longAgo := time.Now().Add(-90 * time.Second)
+	recently := time.Now().Add(-5 * time.Second)
+
+	tests := []struct {
+		name         string
+		setup        func(t *testing.T, f fakes)
+		req          models.MatchRequest
+		wantStatus   string
+		wantQuestion string
+		wantRelaxed  []string
+		wantUsers    []string
+	}{
+		{
+			name:       "empty queue waits",
+			req:        models.MatchRequest{UserID: "u2", Topics: []string{"array"}, Difficulty: "easy"},
+			wantStatus: "waiting",
+		},
+		{
+			name: "same criteria matches the waiting user",
+			setup: func(t *testing.T, f fakes) {
+				f.queueUser(t, "u1", []string{"array"}, "easy", recently)
+			},
+			req:          models.MatchRequest{UserID: "u2", Topics: []string{"array"}, Difficulty: "easy"},
+			wantStatus:   models.MatchStatusMatched,
+			wantQuestion: "q1",
+			wantUsers:    []string{"u1", "u2"},
+		},
+		{
+			name: "skips a question either user has completed",
+			setup: func(t *testing.T, f fakes) {
+				f.queueUser(t, "u1", []string{"array"}, "easy", recently)
+				f.users.Completed["u1"] = []string{"q1"}
+			},
+			req:          models.MatchRequest{UserID: "u2", Topics: []string{"array"}, Difficulty: "easy"},
+			wantStatus:   models.MatchStatusMatched,
+			wantQuestion: "q2",
+			wantUsers:    []string{"u1", "u2"},
+		},
+		{
+			name: "no question both users have not completed",
+			setup: func(t *testing.T, f fakes) {
+				f.queueUser(t, "u1", []string{"array"}, "easy", recently)
+				f.users.Completed["u1"] = []string{"q1", "q2"}
+				f.users.Completed["u2"] = []string{"q1", "q2"}
+			},
+			req:        models.MatchRequest{UserID: "u2", Topics: []string{"array"}, Difficulty: "easy"},
+			wantStatus: "no_suitable_question",
+		},
+		{
+			name: "different topics wait before the relaxation threshold",
+			setup: func(t *testing.T, f fakes) {
+				f.queueUser(t, "u1", []string{"array", "graph"}, "easy", recently)
+			},
+			req:        models.MatchRequest{UserID: "u2", Topics: []string{"array"}, Difficulty: "easy"},
+			wantStatus: "waiting",
+		},
+		{
+			name: "shared topic matches after the relaxation threshold",
+			setup: func(t *testing.T, f fakes) {
+				f.queueUser(t, "u1", []string{"array", "graph"}, "easy", longAgo)
+			},
+			req:          models.MatchRequest{UserID: "u2", Topics: []string{"array"}, Difficulty: "easy"},
+			wantStatus:   models.MatchStatusMatched,
+			wantQuestion: "q1",
+			wantRelaxed:  []string{RelaxedTopics},
+			wantUsers:    []string{"u1", "u2"},
+		},
+		{
+			name: "adjacent difficulty matches after the relaxation threshold",
+			setup: func(t *testing.T, f fakes) {
+				f.queueUser(t, "u1", []string{"graph"}, "easy", longAgo)
+			},
+			req:          models.MatchRequest{UserID: "u2", Topics: []string{"graph"}, Difficulty: "medium"},
+			wantStatus:   models.MatchStatusMatched,
+			wantQuestion: "q3",
+			wantRelaxed:  []string{RelaxedDifficulty},
+			wantUsers:    []string{"u1", "u2"},
+		},
+		{
+			name: "user without a heartbeat is not paired",
+			setup: func(t *testing.T, f fakes) {
+				_, queueKey := buildQueueKey([]string{"array"}, "easy")
+				_ = f.store.Requeue(context.Background(), queueKey, "u1", recently, time.Hour)
+			},
+			req:        models.MatchRequest{UserID: "u2", Topics: []string{"array"}, Difficulty: "easy"},
+			wantStatus: "waiting",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, f := newFakeService(t, testOptions)
+			if tt.setup != nil {
+				tt.setup(t, f)
+			}
+			res, err := service.RequestMatch(context.Background(), tt.req)
+			if err != nil {
+				t.Fatalf("RequestMatch returned error: %v", err)
+			}
+			if res.Status != tt.wantStatus {
+				t.Fatalf("status = %q, want %q", res.Status, tt.wantStatus)
+			}
+			if res.QuestionID != tt.wantQuestion {
+				t.Errorf("question = %q, want %q", res.QuestionID, tt.wantQuestion)
+			}
+			if !equalStrings(res.Relaxed, tt.wantRelaxed) {
+				t.Errorf("relaxed = %v, want %v", res.Relaxed, tt.wantRelaxed)
+			}
+			if !equalStrings(res.UserIDs, tt.wantUsers) {
+				t.Errorf("users = %v, want %v", res.UserIDs, tt.wantUsers)
+			}
+		})
+	}
+}
+
+func TestCancelByUserWithFakes(t *testing.T) {
+	tests := []struct {
+		name      string
+		requeue   bool
+		setup     func(t *testing.T, s *MatchingService, f fakes)
+		wantState string
+		// wantQueued lists the users expected to be in the array/easy queue afterwards
+		wantQueued []string
+	}{
+		{
+			name:       "unknown user",
+			wantState:  "not_found",
+			wantQueued: []string{},
+		},
+		{
+			name: "waiting user leaves the queue",
+			setup: func(t *testing.T, s *MatchingService, f fakes) {
+				mustRequest(t, s, "u1")
+			},
+			wantState:  "cancelled_waiting",
+			wantQueued: []string{},
+		},
+		{
+			name:    "matched user releases the partner into the queue",
+			requeue: true,
+			setup: func(t *testing.T, s *MatchingService, f fakes) {
+				f.queueUser(t, "u2", []string{"array"}, "easy", time.Now().Add(-time.Minute))
+				mustRequest(t, s, "u1")
+			},
+			wantState:  "cancelled_matched",
+			wantQueued: []string{"u2"},
+		},
+		{
+			name:    "matched user releases the partner without requeueing",
+			requeue: false,
+			setup: func(t *testing.T, s *MatchingService, f fakes) {
+				f.queueUser(t, "u2", []string{"array"}, "easy", time.Now().Add(-time.Minute))
+				mustRequest(t, s, "u1")
+			},
+			wantState:  "cancelled_matched",
+			wantQueued: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := testOptions
+			opts.RequeuePartnerOnCancel = tt.requeue
+			service, f := newFakeService(t, opts)
+			ctx := context.Background()
+			if tt.setup != nil {
+				tt.setup(t, service, f)
+			}
+
+			state, _, err := service.CancelByUser(ctx, "u1")
+			if err != nil {
+				t.Fatalf("CancelByUser returned error: %v", err)
+			}
+			if state != tt.wantState {
+				t.Fatalf("state = %q, want %q", state, tt.wantState)
+			}
+			for _, userID := range []string{"u1", "u2"} {
+				if matchID, err := f.store.GetUserMatch(ctx, userID); err == nil {
+					t.Errorf("%s still maps to match %s", userID, matchID)
+				}
+			}
+			queues, _ := f.store.GetAllQueueUsers(ctx)
+			_, queueKey := buildQueueKey([]string{"array"}, "easy")
+			if got := queues[queueKey]; !equalStrings(got, tt.wantQueued) {
+				t.Errorf("queued = %v, want %v", got, tt.wantQueued)
+			}
+		})
+	}
+}
+
+func TestCheckUserStatusWithFakes(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       func(*Options)
+		setup      func(t *testing.T, s *MatchingService, f fakes)
+		wantStatus int
+	}{
+		{
+			name:       "unknown user",
+			wantStatus: UserStatusNone,
+		},
+		{
+			name: "waiting",
+			setup: func(t *testing.T, s *MatchingService, f fakes) {
+				mustRequest(t, s, "u1")
+			},
+			wantStatus: UserStatusWaiting,
+		},
+		{
+			name: "matched",
+			setup: func(t *testing.T, s *MatchingService, f fakes) {
+				f.queueUser(t, "u2", []string{"array"}, "easy", time.Now())
+				mustRequest(t, s, "u1")
+			},
+			wantStatus: UserStatusMatched,
+		},
+		{
+			name: "awaiting acceptance",
+			opts: func(o *Options) { o.AcceptTimeout = time.Minute },
+			setup: func(t *testing.T, s *MatchingService, f fakes) {
+				f.queueUser(t, "u2", []string{"array"}, "easy", time.Now())
+				mustRequest(t, s, "u1")
+			},
+			wantStatus: UserStatusPending,
+		},
+		{
+			name: "waited past the max queue time",
+			setup: func(t *testing.T, s *MatchingService, f fakes) {
+				f.queueUser(t, "u1", []string{"array"}, "easy", time.Now().Add(-time.Hour))
+			},
+			wantStatus: UserStatusTimedOut,
+		},
+		{
+			name: "stopped heartbeating",
+			setup: func(t *testing.T, s *MatchingService, f fakes) {
+				_, queueKey := buildQueueKey([]string{"array"}, "easy")
+				_ = f.store.Requeue(context.Background(), queueKey, "u1", time.Now(), time.Hour)
+			},
+			wantStatus: UserStatusTimedOut,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := testOptions
+			if tt.opts != nil {
+				tt.opts(&opts)
+			}
+			service, f := newFakeService(t, opts)
+			if tt.setup != nil {
+				tt.setup(t, service, f)
+			}
+			status, details, err := service.CheckUserStatus(context.Background(), "u1")
+			if err != nil {
+				t.Fatalf("CheckUserStatus returned error: %v", err)
+			}
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d (%v), want %d", status, details, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestSelectQuestionWithFakes(t *testing.T) {
+	tests := []struct {
+		name       string
+		completed  map[string][]string
+		topics     []string
+		difficulty string
+		lookupErr  error
+		want       string
+		// wantCoverage defaults to the requested topics
+		wantCoverage []string
+		wantErr      bool
+	}{
+		{
+			name:       "first unseen question",
+			topics:     []string{"array"},
+			difficulty: "easy",
+			want:       "q1",
+		},
+		{
+			name:       "skips questions either user completed",
+			completed:  map[string][]string{"u1": {"q1"}},
+			topics:     []string{"array"},
+			difficulty: "easy",
+			want:       "q2",
+		},
+		{
+			name:       "falls back to a question only one user completed",
+			completed:  map[string][]string{"u1": {"q1", "q2"}, "u2": {"q1"}},
+			topics:     []string{"array"},
+			difficulty: "easy",
+			want:       "q2",
+		},
+		{
+			name:       "both users completed everything",
+			completed:  map[string][]string{"u1": {"q1", "q2"}, "u2": {"q1", "q2"}},
+			topics:     []string{"array"},
+			difficulty: "easy",
+			wantErr:    true,
+		},
+		{
+			name:       "no question for the criteria",
+			topics:     []string{"graph"},
+			difficulty: "hard",
+			wantErr:    true,
+		},
+		{
+			name:       "prefers a question tagged with several requested topics",
+			topics:     []string{"graph", "dynamic-programming"},
+			difficulty: "medium",
+			want:       "q5",
+		},
+		{
+			name:         "reports partial coverage",
+			completed:    map[string][]string{"u1": {"q5"}},
+			topics:       []string{"graph", "dynamic-programming"},
+			difficulty:   "medium",
+			want:         "q3",
+			wantCoverage: []string{"graph"},
+		},
+		{
+			name:       "question service unavailable",
+			topics:     []string{"array"},
+			difficulty: "easy",
+			lookupErr:  errors.New("connection refused"),
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, f := newFakeService(t, testOptions)
+			for userID, ids := range tt.completed {
+				f.users.Completed[userID] = ids
+			}
+			f.questions.Err = tt.lookupErr
+
+			q, err := service.selectQuestion(context.Background(), "u1", "u2", tt.topics, tt.difficulty)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("selectQuestion = %s, want an error", q.question.ID)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("selectQuestion returned error: %v", err)
+			}
+			wantCoverage := tt.wantCoverage
+			if wantCoverage == nil {
+				wantCoverage = tt.topics
+			}
+			if q.question.ID != tt.want || !equalStrings(q.coverage, wantCoverage) {
+				t.Fatalf("question = %s covering %v, want %s covering %v", q.question.ID, q.coverage, tt.want, wantCoverage)
+			}
+		})
+	}
+}
+
+func TestSelectQuestionRotatesAcrossTopics(t *testing.T) {
+	service, f := newFakeService(t, testOptions)
+	// With the question covering both topics done, graph and dynamic programming tie
+	f.users.Completed["u1"] = []string{"q5"}
+	ctx := context.Background()
+
+	var picked []string
+	for i := 0; i < 4; i++ {
+		q, err := service.selectQuestion(ctx, "u1", "u2", []string{"graph", "dynamic-programming"}, "medium")
+		if err != nil {
+			t.Fatalf("selectQuestion returned error: %v", err)
+		}
+		picked = append(picked, q.question.ID)
+	}
+	if !equalStrings(picked, []string{"q3", "q4", "q3", "q4"}) {
+		t.Fatalf("picked = %v, want alternating graph and dynamic programming questions", picked)
+	}
+}
+
+func mustRequest(t *testing.T, s *MatchingService, userID string) *models.MatchResponse {
+	t.Helper()
+	res, err := s.RequestMatch(context.Background(), models.MatchRequest{UserID: userID, Topics: []string{"array"}, Difficulty: "easy"})
+	if err != nil {
+		t.Fatalf("RequestMatch(%s) returned error: %v", userID, err)
+	}
+	return res
+}
+
+// equalStrings compares two lists, treating nil and empty as equal
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// tagFilteringLookup mimics question-service, which only returns the tags a question matched on
+type tagFilteringLookup struct {
+	*repository.MemoryQuestionLookup
+}
+
+func (l tagFilteringLookup) GetQuestionsByDifficultyAndTag(ctx context.Context, difficulty, tag string, size int) ([]repository.Question, error) {
+	questions, err := l.MemoryQuestionLookup.GetQuestionsByDifficultyAndTag(ctx, difficulty, tag, size)
+	for i := range questions {
+		questions[i].TopicTags = []repository.TopicTag{{Name: tag, Slug: tag}}
+	}
+	return questions, err
+}
+
+func TestSelectQuestionMergesCoverageAcrossTopicQueries(t *testing.T) {
+	history := repository.NewMemoryMatchHistoryRepository()
+	service := NewMatchingService(
+		repository.NewMemoryMatchStore(),
+		repository.NewMemoryUserLookup(),
+		tagFilteringLookup{repository.NewMemoryQuestionLookup(fakeQuestions...)},
+		history,
+		testOptions,
+	)
+
+	q, err := service.selectQuestion(context.Background(), "u1", "u2", []string{"graph", "dynamic-programming"}, "medium")
+	if err != nil {
+		t.Fatalf("selectQuestion returned error: %v", err)
+	}
+	if q.question.ID != "q5" || !equalStrings(q.coverage, []string{"graph", "dynamic-programming"}) {
+		t.Fatalf("question = %s covering %v, want q5 covering both topics", q.question.ID, q.coverage)
+	}
+}
+
+func TestRequestMatchRecordsMetrics(t *testing.T) {
+	ctx := context.Background()
+	opts := testOptions
+	opts.Metrics = metrics.New()
+	service, f := newFakeService(t, opts)
+
+	f.queueUser(t, "u1", []string{"array"}, "easy", time.Now().Add(-45*time.Second))
+	if _, err := service.RequestMatch(ctx, models.MatchRequest{UserID: "u2", Topics: []string{"array"}, Difficulty: "easy"}); err != nil {
+		t.Fatalf("RequestMatch returned error: %v", err)
+	}
+	f.queueUser(t, "u3", []string{"graph"}, "hard", time.Now())
+	if _, err := service.RequestMatch(ctx, models.MatchRequest{UserID: "u4", Topics: []string{"graph"}, Difficulty: "hard"}); err != nil {
+		t.Fatalf("RequestMatch returned error: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	opts.Metrics.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	for _, line := range []string{
+		`matching_match_outcomes_total{outcome="matched"} 1`,
+		`matching_match_outcomes_total{outcome="no_suitable_question"} 1`,
+		`matching_queue_wait_seconds_bucket{le="30"} 1`,
+		`matching_queue_wait_seconds_count 2`,
+	} {
+		if !strings.Contains(w.Body.String(), line) {
+			t.Errorf("metrics missing %q", line)
+		}
+	}
+}
+
+func TestRequestMatchRejectedAfterStopAccepting(t *testing.T) {
+	ctx := context.Background()
+	service, f := newFakeService(t, testOptions)
+	f.queueUser(t, "u1", []string{"array"}, "easy", time.Now())
+	service.StopAccepting()
+
+	if _, err := service.RequestMatch(ctx, models.MatchRequest{UserID: "u2", Topics: []string{"array"}, Difficulty: "easy"}); !errors.Is(err, ErrShuttingDown) {
+		t.Fatalf("RequestMatch returned %v, want ErrShuttingDown", err)
+	}
+	if _, err := f.store.GetUserQueue(ctx, "u2"); !errors.Is(err, repository.ErrNotFound) {
+		t.Fatalf("rejected user was queued: %v", err)
+	}
+	if queue, _ := f.store.GetUserQueue(ctx, "u1"); queue == "" {
+		t.Fatal("waiting user should stay queued")
+	}
+}
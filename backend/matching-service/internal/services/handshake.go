@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+
+	"matching-service/internal/metrics"
+	"matching-service/internal/models"
+	"matching-service/internal/repository"
+)
+
+var (
+	ErrMatchNotFound   = errors.New("match not found")
+	ErrNotParticipant  = errors.New("user is not part of this match")
+	ErrMatchNotPending = errors.New("match is not awaiting acceptance")
+	errNotOverdue      = errors.New("accept deadline has not passed")
+)
+
+// AcceptMatch records the user's acceptance of a pending match. Once both users have
+// accepted the match becomes "matched" and both are notified.
+func (s *MatchingService) AcceptMatch(ctx context.Context, matchID, userID string) (*models.MatchResponse, error) {
+	if err := s.expireIfOverdue(ctx, matchID); err != nil {
+		return nil, err
+	}
+
+	var becameMatched bool
+	data, err := s.repo.UpdateMatch(ctx, matchID, func(m *repository.MatchData) error {
+		becameMatched = false
+		if !slices.Contains(m.UserIDs, userID) {
+			return ErrNotParticipant
+		}
+		if m.Status == models.MatchStatusMatched {
+			return nil // Accepting twice is harmless
+		}
+		if m.Status != models.MatchStatusPendingAccept {
+			return ErrMatchNotPending
+		}
+		if !slices.Contains(m.AcceptedBy, userID) {
+			m.AcceptedBy = append(m.AcceptedBy, userID)
+		}
+		if len(m.AcceptedBy) == len(m.UserIDs) {
+			m.Status = models.MatchStatusMatched
+			becameMatched = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, matchError(err)
+	}
+
+	if becameMatched {
+		_ = s.repo.RemovePendingAccept(ctx, matchID)
+		s.recordHistory(ctx, matchID, data, models.OutcomeMatched)
+		for _, id := range data.UserIDs {
+			event := models.MatchEvent{Type: models.EventMatched, MatchID: matchID, PartnerID: partnerOf(data, id), QuestionID: data.QuestionID}
+			s.publishEvent(ctx, id, event)
+		}
+	}
+	return data.Response(matchID), nil
+}
+
+// DeclineMatch rejects a pending match. The partner goes back to their queue with
+// their original priority; the decliner does not.
+func (s *MatchingService) DeclineMatch(ctx context.Context, matchID, userID string) error {
+	if err := s.expireIfOverdue(ctx, matchID); err != nil {
+		return err
+	}
+
+	data, err := s.repo.UpdateMatch(ctx, matchID, func(m *repository.MatchData) error {
+		if !slices.Contains(m.UserIDs, userID) {
+			return ErrNotParticipant
+		}
+		if m.Status != models.MatchStatusPendingAccept {
+			return ErrMatchNotPending
+		}
+		m.Status = models.MatchStatusDeclined
+		return nil
+	})
+	if err != nil {
+		return matchError(err)
+	}
+	s.resolveFailedHandshake(ctx, matchID, data, userID)
+	return nil
+}
+
+// ExpireOverdueHandshakes fails every pending match whose accept deadline has passed.
+func (s *MatchingService) ExpireOverdueHandshakes(ctx context.Context) error {
+	matchIDs, err := s.repo.GetOverduePendingAccepts(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+	for _, matchID := range matchIDs {
+		if err := s.expireIfOverdue(ctx, matchID); err != nil {
+			slog.ErrorContext(ctx, "failed to expire pending match", "match_id", matchID, "error", err)
+		}
+	}
+	return nil
+}
+
+// expireIfOverdue fails the match if it is still pending past its accept deadline.
+// Users who accepted are requeued with their original priority; the rest time out.
+func (s *MatchingService) expireIfOverdue(ctx context.Context, matchID string) error {
+	now := time.Now().Unix()
+	data, err := s.repo.UpdateMatch(ctx, matchID, func(m *repository.MatchData) error {
+		if m.Status != models.MatchStatusPendingAccept {
+			return ErrMatchNotPending
+		}
+		if m.AcceptBy > now {
+			return errNotOverdue
+		}
+		m.Status = models.MatchStatusExpired
+		return nil
+	})
+	switch {
+	case err == nil:
+		s.resolveFailedHandshake(ctx, matchID, data, "")
+		return nil
+	case errors.Is(err, repository.ErrNotFound):
+		// The match record is gone, so it can no longer be pending
+		_ = s.repo.RemovePendingAccept(ctx, matchID)
+		return nil
+	case errors.Is(err, ErrMatchNotPending), errors.Is(err, errNotOverdue):
+		return nil
+	}
+	return err
+}
+
+// resolveFailedHandshake releases both users of a declined or expired match. When decliner is
+// set everyone else is requeued; otherwise only the users who accepted are.
+func (s *MatchingService) resolveFailedHandshake(ctx context.Context, matchID string, data *repository.MatchData, decliner string) {
+	_ = s.repo.RemovePendingAccept(ctx, matchID)
+	reason, outcome, observed := models.ReasonPartnerTimeout, models.OutcomeExpired, metrics.OutcomeTimeout
+	if decliner != "" {
+		reason, outcome, observed = models.ReasonPartnerDeclined, models.OutcomeDeclined, metrics.OutcomeCancelled
+	}
+	s.recordHistory(ctx, matchID, data, outcome)
+	s.metrics.ObserveMatchOutcome(observed)
+	for i, userID := range data.UserIDs {
+		requeue := slices.Contains(data.AcceptedBy, userID)
+		if decliner != "" {
+			requeue = userID != decliner
+		}
+
+		switch {
+		case requeue:
+			if err := s.requeueUser(ctx, data, i, reason); err != nil {
+				slog.ErrorContext(ctx, "failed to requeue user after failed handshake", "user_id", userID, "match_id", matchID, "error", err)
+			}
+		case userID == decliner:
+			_ = s.repo.ClearUserMatch(ctx, userID, matchID)
+			s.publishEvent(ctx, userID, models.MatchEvent{Type: models.EventCancelled, MatchID: matchID})
+		default:
+			// Did not accept in time
+			_ = s.repo.ClearUserMatch(ctx, userID, matchID)
+			if i < len(data.QueueKeys) {
+				_ = s.repo.MarkUserTimedOut(ctx, userID, data.QueueKeys[i], defaultTTL)
+			}
+			s.publishEvent(ctx, userID, models.MatchEvent{Type: models.EventTimeout, MatchID: matchID})
+		}
+	}
+}
+
+// requeueUser puts participant i of a match back into their original queue, scored by their
+// original enqueue time so they keep their place in line.
+func (s *MatchingService) requeueUser(ctx context.Context, data *repository.MatchData, i int, reason string) error {
+	if i >= len(data.QueueKeys) || i >= len(data.EnqueuedAt) {
+		return errors.New("match record has no queue information")
+	}
+	userID, queueKey := data.UserIDs[i], data.QueueKeys[i]
+	if err := s.repo.Requeue(ctx, queueKey, userID, time.Unix(data.EnqueuedAt[i], 0), s.queueMappingTTL()); err != nil {
+		return err
+	}
+	if s.heartbeatGrace > 0 {
+		_ = s.repo.RefreshUserAlive(ctx, userID, s.heartbeatGrace)
+	}
+	s.publishWaiting(ctx, userID, queueKey, reason)
+	// Everyone the user went back ahead of moved down a place
+	s.publishQueuePositions(ctx, queueKey)
+	return nil
+}
+
+func partnerOf(data *repository.MatchData, userID string) string {
+	for _, id := range data.UserIDs {
+		if id != userID {
+			return id
+		}
+	}
+	return ""
+}
+
+// matchError maps a missing match record to ErrMatchNotFound
+func matchError(err error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrMatchNotFound
+	}
+	return err
+}
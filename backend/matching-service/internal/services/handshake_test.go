@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"matching-service/internal/models"
+	"matching-service/internal/repository"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// pendingPair seeds two users into the same queue and pairs them, returning the pending matchId
+func pendingPair(t *testing.T, service *MatchingService, mr *miniredis.Miniredis) (string, string) {
+	t.Helper()
+	ctx := context.Background()
+	_, queueKey := buildQueueKey([]string{"array"}, "easy")
+	seedQueue(t, mr, queueKey, "u1", time.Now().Add(-2*time.Minute))
+	seedQueue(t, mr, queueKey, "u2", time.Now().Add(-time.Minute))
+	if err := service.repo.SaveUserQueue(ctx, "u1", queueKey, time.Hour); err != nil {
+		t.Fatalf("seeding queue mapping: %v", err)
+	}
+	if err := service.repo.SaveUserQueue(ctx, "u2", queueKey, time.Hour); err != nil {
+		t.Fatalf("seeding queue mapping: %v", err)
+	}
+
+	if _, err := service.RunMatchmakingPass(ctx); err != nil {
+		t.Fatalf("RunMatchmakingPass returned error: %v", err)
+	}
+	matchID, err := service.CheckUserMatch(ctx, "u1")
+	if err != nil {
+		t.Fatalf("u1 was not paired: %v", err)
+	}
+	if status, _, _ := service.CheckUserStatus(ctx, "u1"); status != UserStatusPending {
+		t.Fatalf("status = %d, want %d", status, UserStatusPending)
+	}
+	return matchID, queueKey
+}
+
+func handshakeOptions() Options {
+	opts := testOptions
+	opts.AcceptTimeout = 15 * time.Second
+	return opts
+}
+
+func TestAcceptMatchByBothUsersFinalisesMatch(t *testing.T) {
+	service, mr := newTestServiceWithOptions(t, handshakeOptions())
+	ctx := context.Background()
+	matchID, _ := pendingPair(t, service, mr)
+
+	res, err := service.AcceptMatch(ctx, matchID, "u1")
+	if err != nil || res.Status != models.MatchStatusPendingAccept {
+		t.Fatalf("first accept = %+v, %v; want still pending", res, err)
+	}
+	if _, err := service.AcceptMatch(ctx, matchID, "intruder"); !errors.Is(err, ErrNotParticipant) {
+		t.Fatalf("accept by outsider err = %v, want ErrNotParticipant", err)
+	}
+	res, err = service.AcceptMatch(ctx, matchID, "u2")
+	if err != nil || res.Status != models.MatchStatusMatched {
+		t.Fatalf("second accept = %+v, %v; want matched", res, err)
+	}
+	if status, _, _ := service.CheckUserStatus(ctx, "u2"); status != UserStatusMatched {
+		t.Fatalf("status = %d, want %d", status, UserStatusMatched)
+	}
+}
+
+func TestDeclineMatchRequeuesPartnerWithOriginalPriority(t *testing.T) {
+	service, mr := newTestServiceWithOptions(t, handshakeOptions())
+	ctx := context.Background()
+	matchID, queueKey := pendingPair(t, service, mr)
+	originalScore := float64(time.Now().Add(-2 * time.Minute).Unix())
+
+	// Someone else joins while the handshake is in progress
+	seedQueue(t, mr, queueKey, "u3", time.Now())
+
+	if _, err := service.AcceptMatch(ctx, matchID, "u1"); err != nil {
+		t.Fatalf("AcceptMatch returned error: %v", err)
+	}
+	if err := service.DeclineMatch(ctx, matchID, "u2"); err != nil {
+		t.Fatalf("DeclineMatch returned error: %v", err)
+	}
+
+	score, err := mr.ZScore(queueKey, "u1")
+	if err != nil || score != originalScore {
+		t.Fatalf("u1 score = %v (%v), want original %v", score, err, originalScore)
+	}
+	members, _ := mr.ZMembers(queueKey)
+	if len(members) != 2 || members[0] != "u1" || members[1] != "u3" {
+		t.Fatalf("queue = %v, want [u1 u3]; the decliner should not be requeued", members)
+	}
+	if err := service.DeclineMatch(ctx, matchID, "u1"); !errors.Is(err, ErrMatchNotPending) {
+		t.Fatalf("second decline err = %v, want ErrMatchNotPending", err)
+	}
+}
+
+func TestExpiredHandshakeRequeuesOnlyAcceptedUser(t *testing.T) {
+	service, mr := newTestServiceWithOptions(t, handshakeOptions())
+	ctx := context.Background()
+	matchID, queueKey := pendingPair(t, service, mr)
+
+	if _, err := service.AcceptMatch(ctx, matchID, "u1"); err != nil {
+		t.Fatalf("AcceptMatch returned error: %v", err)
+	}
+	// Move the deadline into the past; u2 never responded
+	if _, err := service.repo.UpdateMatch(ctx, matchID, func(m *repository.MatchData) error {
+		m.AcceptBy = time.Now().Add(-time.Second).Unix()
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateMatch returned error: %v", err)
+	}
+
+	if err := service.ExpireOverdueHandshakes(ctx); err != nil {
+		t.Fatalf("ExpireOverdueHandshakes returned error: %v", err)
+	}
+	if status, details, _ := service.CheckUserStatus(ctx, "u1"); status != UserStatusWaiting || details["queue"] != queueKey {
+		t.Fatalf("u1 status = %d %v, want waiting in %s", status, details, queueKey)
+	}
+	if status, _, _ := service.CheckUserStatus(ctx, "u2"); status != UserStatusTimedOut {
+		t.Fatalf("u2 status = %d, want %d", status, UserStatusTimedOut)
+	}
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"matching-service/internal/models"
+	"matching-service/internal/repository"
+)
+
+// recordHistory persists the match with its outcome; history is best-effort and never fails a match
+func (s *MatchingService) recordHistory(ctx context.Context, matchID string, data *repository.MatchData, outcome string) {
+	if s.history == nil {
+		return
+	}
+	record := models.MatchHistoryRecord{
+		MatchID:       matchID,
+		UserIDs:       data.UserIDs,
+		Difficulty:    data.Difficulty,
+		Topics:        data.Topics,
+		QuestionID:    data.QuestionID,
+		QuestionTitle: data.QuestionTitle,
+		QuestionSlug:  data.QuestionSlug,
+		Outcome:       outcome,
+		MatchedAt:     data.CreatedAt,
+		UpdatedAt:     time.Now(),
+	}
+	if err := s.history.Save(ctx, record); err != nil {
+		slog.ErrorContext(ctx, "failed to record match history", "match_id", matchID, "error", err)
+	}
+}
+
+// GetMatchHistory returns a page of the user's past matches, newest first
+func (s *MatchingService) GetMatchHistory(ctx context.Context, userID string, limit, offset int) (*models.MatchHistoryPage, error) {
+	page := &models.MatchHistoryPage{Items: []models.MatchHistoryEntry{}, Limit: limit, Offset: offset}
+	if s.history == nil {
+		return page, nil
+	}
+	records, total, err := s.history.ListByUser(ctx, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	page.Total = total
+	for _, record := range records {
+		entry := models.MatchHistoryEntry{
+			MatchID:       record.MatchID,
+			QuestionID:    record.QuestionID,
+			QuestionTitle: record.QuestionTitle,
+			QuestionSlug:  record.QuestionSlug,
+			Topics:        record.Topics,
+			Difficulty:    record.Difficulty,
+			Outcome:       record.Outcome,
+			MatchedAt:     record.MatchedAt,
+		}
+		for _, id := range record.UserIDs {
+			if id != userID {
+				entry.PartnerID = id
+			}
+		}
+		page.Items = append(page.Items, entry)
+	}
+	return page, nil
+}
@@ -2,23 +2,79 @@ package services
 
 import (
 	"context"
-	"fmt"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
 	"matching-service/internal/constants"
+	"matching-service/internal/metrics"
 	"matching-service/internal/models"
 	"matching-service/internal/repository"
+	"matching-service/internal/tracing"
 	"sort"
 	"strings"
+	"sync/atomic"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type MatchingService struct {
-	repo         *repository.MatchRepository
-	userRepo     *repository.UserRepository
-	questionRepo *repository.QuestionRepository
+	repo           repository.MatchStore
+	userRepo       repository.UserLookup
+	questionRepo   repository.QuestionLookup
+	history        repository.MatchHistoryRepository
+	audit          repository.AuditLogRepository
+	metrics        *metrics.Metrics
+	relaxation     RelaxationPolicy
+	maxQueueWait   time.Duration
+	heartbeatGrace time.Duration
+	acceptTimeout  time.Duration
+	matchBudget    time.Duration
+
+	requeuePartnerOnCancel bool
+
+	// topicRotation picks which topic leads question selection, so ties rotate across topics
+	topicRotation atomic.Uint64
+	// stopping is set once shutdown begins; new match requests are then rejected
+	stopping atomic.Bool
+}
+
+// Options tunes matching behaviour
+type Options struct {
+	Relaxation RelaxationPolicy
+	// MaxQueueWait evicts users who have waited longer than this; zero disables eviction
+	MaxQueueWait time.Duration
+	// HeartbeatGrace evicts waiting users who have not sent a heartbeat for this long; zero disables the check
+	HeartbeatGrace time.Duration
+	// AcceptTimeout requires both users to accept a match within this long; zero makes matches final immediately
+	AcceptTimeout time.Duration
+	// RequeuePartnerOnCancel puts the partner of a user who cancels back in their queue
+	RequeuePartnerOnCancel bool
+	// MatchBudget bounds the calls to user-service and question-service made to pick a
+	// question for a match; zero leaves them bounded only by the caller's context
+	MatchBudget time.Duration
+	// Audit records admin actions; when nil they are only logged
+	Audit repository.AuditLogRepository
+	// Metrics records queue waits and match outcomes; nil records nothing
+	Metrics *metrics.Metrics
+}
+
+// pairing is a candidate pair of users and the criteria their match is formed on
+type pairing struct {
+	users      []string
+	queueKeys  []string // queueKeys[i] is the queue holding users[i]
+	topics     []string
+	difficulty string
+	relaxed    []string
 }
 
 const defaultTTL = 10 * time.Minute
 
+// maxClaimAttempts bounds how often RequestMatch retries after losing a pair to a concurrent request
+const maxClaimAttempts = 3
+
 func buildQueueKey(topics []string, difficulty string) (joined string, queueKey string) {
 	copyTopics := append([]string{}, topics...)
 	sort.Strings(copyTopics)
@@ -27,123 +83,262 @@ func buildQueueKey(topics []string, difficulty string) (joined string, queueKey
 	return
 }
 
-func NewMatchingService(repo *repository.MatchRepository, userRepo *repository.UserRepository, questionRepo *repository.QuestionRepository) *MatchingService {
+func NewMatchingService(repo repository.MatchStore, userRepo repository.UserLookup, questionRepo repository.QuestionLookup, history repository.MatchHistoryRepository, opts Options) *MatchingService {
 	return &MatchingService{
-		repo:         repo,
-		userRepo:     userRepo,
-		questionRepo: questionRepo,
+		repo:           repo,
+		userRepo:       userRepo,
+		questionRepo:   questionRepo,
+		history:        history,
+		audit:          opts.Audit,
+		metrics:        opts.Metrics,
+		relaxation:     opts.Relaxation,
+		maxQueueWait:   opts.MaxQueueWait,
+		heartbeatGrace: opts.HeartbeatGrace,
+		acceptTimeout:  opts.AcceptTimeout,
+		matchBudget:    opts.MatchBudget,
+
+		requeuePartnerOnCancel: opts.RequeuePartnerOnCancel,
 	}
 }
 
-// selectQuestion tries to find a suitable question for the matched users with progressive sampling
-func (s *MatchingService) selectQuestion(ctx context.Context, user1ID, user2ID string, topics []string, difficulty string) (string, error) {
-	// Fetch completed questions for both users
-	user1Completed, err := s.userRepo.GetCompletedQuestions(ctx, user1ID)
+// queueMappingTTL keeps the user -> queue mapping alive at least as long as a user may wait
+func (s *MatchingService) queueMappingTTL() time.Duration {
+	return max(defaultTTL, s.maxQueueWait)
+}
+
+// evictStale removes users from the queue who have waited longer than maxQueueWait or
+// stopped sending heartbeats, and notifies them of the timeout.
+func (s *MatchingService) evictStale(ctx context.Context, queueKey string) error {
+	if s.maxQueueWait <= 0 && s.heartbeatGrace <= 0 {
+		return nil
+	}
+	var cutoff time.Time
+	if s.maxQueueWait > 0 {
+		cutoff = time.Now().Add(-s.maxQueueWait)
+	}
+	timedOut, err := s.repo.EvictStale(ctx, queueKey, cutoff, s.heartbeatGrace > 0, defaultTTL)
 	if err != nil {
-		// If we can't fetch completed questions, just proceed without filtering
-		user1Completed = []string{}
+		return err
+	}
+	for _, userID := range timedOut {
+		slog.InfoContext(ctx, "evicted stale user", "user_id", userID, "queue", queueKey)
+		s.metrics.ObserveMatchOutcome(metrics.OutcomeTimeout)
+		s.publishEvent(ctx, userID, models.MatchEvent{Type: models.EventTimeout, Queue: queueKey})
+	}
+	if len(timedOut) > 0 {
+		s.publishQueuePositions(ctx, queueKey)
 	}
+	return nil
+}
 
-	user2Completed, err := s.userRepo.GetCompletedQuestions(ctx, user2ID)
+// Heartbeat refreshes a waiting user's liveness. It returns false if the user is not waiting.
+func (s *MatchingService) Heartbeat(ctx context.Context, userID string) (bool, error) {
+	queueKey, err := s.repo.GetUserQueue(ctx, userID)
+	if err != nil || queueKey == "" {
+		return false, nil
+	}
+	rank, err := s.repo.GetUserQueueRank(ctx, queueKey, userID)
 	if err != nil {
-		user2Completed = []string{}
+		return false, err
 	}
-
-	// Create a set of questions completed by both users
-	user1Set := make(map[string]bool)
-	for _, qid := range user1Completed {
-		user1Set[qid] = true
+	if rank < 0 {
+		return false, nil
+	}
+	if s.heartbeatGrace <= 0 {
+		return true, nil
 	}
+	return true, s.repo.RefreshUserAlive(ctx, userID, s.heartbeatGrace)
+}
+
+func (s *MatchingService) RequestMatch(ctx context.Context, req models.MatchRequest) (res *models.MatchResponse, err error) {
+	ctx, span := tracing.Start(ctx, "MatchingService.RequestMatch", trace.WithAttributes(
+		attribute.String("match.difficulty", req.Difficulty),
+		attribute.StringSlice("match.topics", req.Topics),
+	))
+	defer func() {
+		if res != nil {
+			span.SetAttributes(attribute.String("match.status", res.Status), attribute.String("match.id", res.MatchID))
+		}
+		tracing.End(span, err)
+	}()
 
-	user2Set := make(map[string]bool)
-	for _, qid := range user2Completed {
-		user2Set[qid] = true
+	if s.ShuttingDown() {
+		return nil, ErrShuttingDown
+	}
+	_, queueKey := buildQueueKey(req.Topics, req.Difficulty)
+	if err := s.repo.Enqueue(ctx, queueKey, req.UserID); err != nil {
+		return nil, err
+	}
+	// Save user's queue association so we can report waiting status by userId
+	_ = s.repo.SaveUserQueue(ctx, req.UserID, queueKey, s.queueMappingTTL())
+	// Queueing again clears any earlier timeout
+	_ = s.repo.ClearUserTimeout(ctx, req.UserID)
+	if s.heartbeatGrace > 0 {
+		_ = s.repo.RefreshUserAlive(ctx, req.UserID, s.heartbeatGrace)
 	}
 
-	// Try progressive sampling: 10, 50, 100
-	sampleSizes := []int{10, 50, 100}
+	// Drop abandoned entries before they can be paired
+	if err := s.evictStale(ctx, queueKey); err != nil {
+		return nil, err
+	}
 
-	// Try to find a question neither user has completed
-	for _, size := range sampleSizes {
-		// Query question service with the first topic (matching service stores multiple topics, but question service queries by single tag)
-		tag := topics[0]
-		questions, err := s.questionRepo.GetQuestionsByDifficultyAndTag(ctx, difficulty, tag, size)
+	// Another request may claim the same pair concurrently, so retry a few times
+	// before giving up and leaving the user waiting.
+	for attempt := 0; attempt < maxClaimAttempts; attempt++ {
+		users, err := s.repo.PeekTwo(ctx, queueKey)
 		if err != nil {
-			continue // Try next sample size
+			return nil, err
 		}
 
-		// Filter questions: prioritize questions neither user has completed
-		for _, q := range questions {
-			if !user1Set[q.ID] && !user2Set[q.ID] {
-				return q.ID, nil
+		var p *pairing
+		if len(users) == 2 {
+			p = &pairing{
+				users:      users,
+				queueKeys:  []string{queueKey, queueKey},
+				topics:     req.Topics,
+				difficulty: req.Difficulty,
+			}
+		} else {
+			// Nobody with identical criteria; look for a partner under the relaxation policy
+			enqueuedAt, err := s.repo.GetEnqueueTime(ctx, queueKey, req.UserID)
+			if err != nil {
+				return &models.MatchResponse{Status: "waiting"}, nil
+			}
+			self := queuedUser{userID: req.UserID, queueKey: queueKey, difficulty: req.Difficulty, topics: req.Topics, enqueuedAt: enqueuedAt}
+			p, err = s.findRelaxedPartner(ctx, self)
+			if err != nil {
+				return nil, err
 			}
 		}
-	}
 
-	// Fallback: Allow questions where only ONE user has completed it
-	for _, size := range sampleSizes {
-		tag := topics[0]
-		questions, err := s.questionRepo.GetQuestionsByDifficultyAndTag(ctx, difficulty, tag, size)
-		if err != nil {
-			continue
+		if p == nil {
+			s.publishWaiting(ctx, req.UserID, queueKey, "")
+			return &models.MatchResponse{Status: "waiting"}, nil
 		}
 
-		for _, q := range questions {
-			// Accept if only one user completed it (not both)
-			if !(user1Set[q.ID] && user2Set[q.ID]) {
-				return q.ID, nil
-			}
+		res, claimed, err := s.formMatch(ctx, p)
+		if errors.Is(err, ErrQuestionsUnavailable) {
+			// Both users stay queued; the matchmaker pairs them once question-service recovers
+			slog.WarnContext(ctx, "question service unavailable; leaving pair queued", "users", p.users, "error", err)
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if claimed {
+			return res, nil
 		}
 	}
-
-	// Final fallback: return "no_suitable_question" status
-	return "", fmt.Errorf("no_suitable_question")
+	s.publishWaiting(ctx, req.UserID, queueKey, "")
+	return &models.MatchResponse{Status: "waiting"}, nil
 }
 
-func (s *MatchingService) RequestMatch(ctx context.Context, req models.MatchRequest) (*models.MatchResponse, error) {
-	joined, queueKey := buildQueueKey(req.Topics, req.Difficulty)
-	if err := s.repo.Enqueue(ctx, queueKey, req.UserID); err != nil {
-		return nil, err
+// formMatch selects a question for the pair and atomically claims both users.
+// It returns claimed=false if a concurrent request took either user first. If
+// question-service cannot be reached the pair stays queued and ErrQuestionsUnavailable
+// is returned, so the pair is tried again once it recovers.
+func (s *MatchingService) formMatch(ctx context.Context, p *pairing) (*models.MatchResponse, bool, error) {
+	// Select a suitable question for the matched users
+	choice, err := s.selectQuestion(ctx, p.users[0], p.users[1], p.topics, p.difficulty)
+	if errors.Is(err, ErrNoSuitableQuestion) {
+		return s.dropPair(ctx, p)
 	}
-	// Save user's queue association so we can report waiting status by userId
-	_ = s.repo.SaveUserQueue(ctx, req.UserID, queueKey, defaultTTL)
-
-	users, err := s.repo.PopTwo(ctx, queueKey)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
+	return s.claimMatch(ctx, p, choice)
+}
 
-	if len(users) < 2 {
-		return &models.MatchResponse{Status: "waiting"}, nil
+// dropPair removes a pair no question suits from their queues, so it does not block the
+// head of the queue, and tells both users why they were let go.
+func (s *MatchingService) dropPair(ctx context.Context, p *pairing) (*models.MatchResponse, bool, error) {
+	dropped, err := s.repo.DropPair(ctx, p.queueKeys, p.users)
+	if err != nil || !dropped {
+		return nil, false, err
+	}
+	ctx = context.WithoutCancel(ctx)
+	s.metrics.ObserveMatchOutcome(metrics.OutcomeNoSuitableQuestion)
+	for i, userID := range p.users {
+		if current, err := s.repo.GetUserQueue(ctx, userID); err == nil && current == p.queueKeys[i] {
+			_ = s.repo.SaveUserQueue(ctx, userID, "", 0)
+		}
+		s.publishEvent(ctx, userID, models.MatchEvent{Type: models.EventCancelled, Queue: p.queueKeys[i], Reason: models.ReasonNoSuitableQuestion})
 	}
+	s.publishPairQueues(ctx, p)
+	// If no suitable question found, return status indicating this
+	return &models.MatchResponse{
+		Status: "no_suitable_question",
+	}, true, nil
+}
 
-	// Select a suitable question for the matched users
-	questionID, err := s.selectQuestion(ctx, users[0], users[1], req.Topics, req.Difficulty)
-	if err != nil {
-		// If no suitable question found, return status indicating this
-		return &models.MatchResponse{
-			Status: "no_suitable_question",
-		}, nil
+// claimMatch atomically claims both users of the pair for a match on the chosen question.
+// It returns claimed=false if a concurrent request took either user first.
+func (s *MatchingService) claimMatch(ctx context.Context, p *pairing, choice *questionChoice) (*models.MatchResponse, bool, error) {
+	question := choice.question
+
+	// Remember original enqueue times so users can be requeued with their priority
+	enqueuedAt := make([]int64, len(p.users))
+	for i, userID := range p.users {
+		t, err := s.repo.GetEnqueueTime(ctx, p.queueKeys[i], userID)
+		if err != nil {
+			// Already taken by a concurrent request
+			return nil, false, nil
+		}
+		enqueuedAt[i] = t.Unix()
 	}
 
-	matchID := fmt.Sprintf("match:%s:%d", joined, time.Now().UnixNano())
-	// Save match with questionID
-	if err := s.repo.SaveMatch(ctx, matchID, users[1], questionID, defaultTTL); err != nil {
-		return nil, err
+	matchID := newMatchID()
+	now := time.Now()
+	matchData := repository.MatchData{
+		UserIDs:       p.users,
+		Difficulty:    p.difficulty,
+		Topics:        p.topics,
+		QuestionID:    question.ID,
+		QuestionTitle: question.Title,
+		QuestionSlug:  question.TitleSlug,
+		TopicCoverage: choice.coverage,
+		Relaxed:       p.relaxed,
+		Status:        models.MatchStatusMatched,
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(defaultTTL),
+		QueueKeys:     p.queueKeys,
+		EnqueuedAt:    enqueuedAt,
 	}
-	// Save reverse lookup so either user can poll by userId
-	if err := s.repo.SaveUserMatch(ctx, users[0], matchID, defaultTTL); err != nil {
-		return nil, err
+	eventType := models.EventMatched
+	if s.acceptTimeout > 0 {
+		// Both users must accept before the match is final
+		matchData.Status = models.MatchStatusPendingAccept
+		matchData.AcceptBy = now.Add(s.acceptTimeout).Unix()
+		eventType = models.EventPendingAccept
 	}
-	if err := s.repo.SaveUserMatch(ctx, users[1], matchID, defaultTTL); err != nil {
-		return nil, err
+	// Pop the pair, save the match and both reverse lookups in one atomic step
+	claimed, err := s.repo.ClaimPair(ctx, p.queueKeys, p.users, matchID, matchData, defaultTTL)
+	if err != nil || !claimed {
+		return nil, false, err
+	}
+	// The pair is claimed, so record and announce the match even if the caller has gone away
+	// or the service is shutting down; otherwise both users would wait for events never sent
+	ctx = context.WithoutCancel(ctx)
+	s.metrics.ObserveMatchOutcome(metrics.OutcomeMatched)
+	for _, t := range enqueuedAt {
+		s.metrics.ObserveQueueWait(now.Sub(time.Unix(t, 0)))
+	}
+	// Notify both users, whichever replica they are connected to
+	if matchData.Status == models.MatchStatusMatched {
+		s.recordHistory(ctx, matchID, &matchData, models.OutcomeMatched)
+	}
+	s.publishEvent(ctx, p.users[0], models.MatchEvent{Type: eventType, MatchID: matchID, PartnerID: p.users[1], QuestionID: question.ID, AcceptBy: matchData.AcceptBy})
+	s.publishEvent(ctx, p.users[1], models.MatchEvent{Type: eventType, MatchID: matchID, PartnerID: p.users[0], QuestionID: question.ID, AcceptBy: matchData.AcceptBy})
+	s.publishPairQueues(ctx, p)
+	return matchData.Response(matchID), true, nil
+}
+
+// publishPairQueues updates positions in the queues a claimed or dropped pair left
+func (s *MatchingService) publishPairQueues(ctx context.Context, p *pairing) {
+	s.publishQueuePositions(ctx, p.queueKeys[0])
+	if p.queueKeys[1] != p.queueKeys[0] {
+		s.publishQueuePositions(ctx, p.queueKeys[1])
 	}
-	return &models.MatchResponse{
-		MatchID:    matchID,
-		UserIDs:  users,
-		QuestionID: questionID,
-		Status:     "matched",
-	}, nil
 }
 
 func (s *MatchingService) CheckMatchStatus(ctx context.Context, matchID string) (*models.MatchResponse, error) {
@@ -154,8 +349,51 @@ func (s *MatchingService) CheckMatchStatus(ctx context.Context, matchID string)
 	return match, nil
 }
 
+// CancelMatch cancels a match by id, releasing and notifying both users.
 func (s *MatchingService) CancelMatch(ctx context.Context, matchID string) error {
-	return s.repo.CancelMatch(ctx, matchID)
+	return s.cancelMatch(ctx, matchID, "")
+}
+
+// cancelMatch deletes the match and clears both users' mappings. When cancelledBy is set the
+// partner is notified and, if configured, put back in their queue with their original priority.
+func (s *MatchingService) cancelMatch(ctx context.Context, matchID, cancelledBy string) error {
+	data, err := s.repo.GetMatchData(ctx, matchID)
+	if err != nil && !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	deleted, err := s.repo.CancelMatch(ctx, matchID)
+	if err != nil {
+		return err
+	}
+	_ = s.repo.RemovePendingAccept(ctx, matchID)
+	if !deleted || data == nil {
+		// Already cancelled elsewhere; only release the caller
+		if cancelledBy != "" {
+			_ = s.repo.ClearUserMatch(ctx, cancelledBy, matchID)
+		}
+		return nil
+	}
+
+	s.recordHistory(ctx, matchID, data, models.OutcomeCancelled)
+	s.metrics.ObserveMatchOutcome(metrics.OutcomeCancelled)
+
+	for i, userID := range data.UserIDs {
+		if userID == cancelledBy || cancelledBy == "" {
+			_ = s.repo.ClearUserMatch(ctx, userID, matchID)
+			s.publishEvent(ctx, userID, models.MatchEvent{Type: models.EventCancelled, MatchID: matchID})
+			continue
+		}
+		if s.requeuePartnerOnCancel {
+			err := s.requeueUser(ctx, data, i, models.ReasonPartnerCancelled)
+			if err == nil {
+				continue
+			}
+			slog.ErrorContext(ctx, "failed to requeue user after match was cancelled", "user_id", userID, "match_id", matchID, "error", err)
+		}
+		_ = s.repo.ClearUserMatch(ctx, userID, matchID)
+		s.publishEvent(ctx, userID, models.MatchEvent{Type: models.EventCancelled, MatchID: matchID, Reason: models.ReasonPartnerCancelled})
+	}
+	return nil
 }
 
 func (s *MatchingService) CheckUserMatch(ctx context.Context, userID string) (string, error) {
@@ -163,14 +401,18 @@ func (s *MatchingService) CheckUserMatch(ctx context.Context, userID string) (st
 }
 
 // CancelByUser cancels either a waiting user (removes from queue) or a matched user (removes match and mappings)
-func (s *MatchingService) CancelByUser(ctx context.Context, userID string) (string, *models.MatchResponse, error) {
+func (s *MatchingService) CancelByUser(ctx context.Context, userID string) (outcome string, res *models.MatchResponse, err error) {
+	ctx, span := tracing.Start(ctx, "MatchingService.CancelByUser")
+	defer func() {
+		span.SetAttributes(attribute.String("match.cancel_outcome", outcome))
+		tracing.End(span, err)
+	}()
+
 	if matchID, err := s.repo.GetUserMatch(ctx, userID); err == nil && matchID != "" {
 		// Matched case: remove match and both user mappings
-		if err := s.repo.CancelMatch(ctx, matchID); err != nil {
+		if err := s.cancelMatch(ctx, matchID, userID); err != nil {
 			return "", nil, err
 		}
-		// Best-effort: remove user match mappings (ignore errors)
-		_ = s.repo.SaveUserMatch(ctx, userID, "", 0)
 		return "cancelled_matched", &models.MatchResponse{MatchID: matchID, Status: "cancelled"}, nil
 	}
 	if queueKey, err := s.repo.GetUserQueue(ctx, userID); err == nil && queueKey != "" {
@@ -179,30 +421,61 @@ func (s *MatchingService) CancelByUser(ctx context.Context, userID string) (stri
 		}
 		// Best-effort: clear queue mapping
 		_ = s.repo.SaveUserQueue(ctx, userID, "", 0)
+		s.metrics.ObserveMatchOutcome(metrics.OutcomeCancelled)
+		s.publishEvent(ctx, userID, models.MatchEvent{Type: models.EventCancelled, Queue: queueKey})
+		s.publishQueuePositions(ctx, queueKey)
 		return "cancelled_waiting", &models.MatchResponse{Status: "cancelled"}, nil
 	}
 	return "not_found", &models.MatchResponse{Status: "not_found"}, nil
 }
 
+// User status codes reported by CheckUserStatus
+const (
+	UserStatusNone     = 0
+	UserStatusWaiting  = 1
+	UserStatusMatched  = 2
+	UserStatusTimedOut = 3
+	UserStatusPending  = 4
+)
+
 // CheckUserStatus returns (statusCode, details)
 // status 2: matched -> details["matchId"]
 // status 1: waiting -> details["queue"], details["position"] (0-based)
+// status 4: paired, waiting for both users to accept -> details["matchId"], details["acceptBy"]
+// status 3: evicted after waiting too long or missing heartbeats -> details["queue"]
 // status 0: not in queue and not matched
 func (s *MatchingService) CheckUserStatus(ctx context.Context, userID string) (int, map[string]any, error) {
 	if matchID, err := s.repo.GetUserMatch(ctx, userID); err == nil && matchID != "" {
-		return 2, map[string]any{"matchId": matchID}, nil
+		match, err := s.repo.GetMatchData(ctx, matchID)
+		if err != nil || match.Status != models.MatchStatusPendingAccept {
+			return UserStatusMatched, map[string]any{"matchId": matchID}, nil
+		}
+		if match.AcceptBy > time.Now().Unix() {
+			return UserStatusPending, map[string]any{"matchId": matchID, "acceptBy": match.AcceptBy}, nil
+		}
+		// The handshake has lapsed; resolve it and report where the user ended up
+		if err := s.expireIfOverdue(ctx, matchID); err != nil {
+			return 0, nil, err
+		}
 	}
 	queueKey, err := s.repo.GetUserQueue(ctx, userID)
 	if err == nil && queueKey != "" {
+		// Evict lazily so a timed out user sees the timeout even if no worker has run yet
+		if err := s.evictStale(ctx, queueKey); err != nil {
+			return 0, nil, err
+		}
 		rank, rerr := s.repo.GetUserQueueRank(ctx, queueKey, userID)
 		if rerr != nil {
-			return 1, map[string]any{"queue": queueKey}, nil
+			return UserStatusWaiting, map[string]any{"queue": queueKey}, nil
 		}
 		if rank >= 0 {
-			return 1, map[string]any{"queue": queueKey, "position": rank}, nil
+			return UserStatusWaiting, map[string]any{"queue": queueKey, "position": rank}, nil
 		}
 	}
-	return 0, nil, nil
+	if queueKey, err := s.repo.GetUserTimeout(ctx, userID); err == nil && queueKey != "" {
+		return UserStatusTimedOut, map[string]any{"queue": queueKey}, nil
+	}
+	return UserStatusNone, nil, nil
 }
 
 // GetQueueUsers returns all users currently in all queues
@@ -216,14 +489,11 @@ func (s *MatchingService) GetQueueUsers(ctx context.Context) ([]models.QueueUser
 	for queueKey, users := range queueUsers {
 		// Parse queue key to extract topics and difficulty
 		// Format: "queue:difficulty:topic1,topic2"
-		parts := strings.Split(queueKey, constants.QueueKeyDelimiter)
-		if len(parts) != constants.QueueKeyParts || parts[0] != constants.QueueKeyPrefix {
+		difficulty, topics, ok := parseQueueKey(queueKey)
+		if !ok {
 			continue // Skip malformed queue keys
 		}
 
-		difficulty := parts[1]
-		topics := strings.Split(parts[2], ",")
-
 		// Add each user in this queue
 		for _, userID := range users {
 			result = append(result, models.QueueUser{
@@ -236,3 +506,11 @@ func (s *MatchingService) GetQueueUsers(ctx context.Context) ([]models.QueueUser
 
 	return result, nil
 }
+
+// newMatchID returns a random match ID, so replicas pairing the same users at the same
+// moment never produce the same one
+func newMatchID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return "match:" + hex.EncodeToString(b)
+}
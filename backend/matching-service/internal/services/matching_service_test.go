@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"matching-service/internal/constants"
+	"matching-service/internal/models"
+	"matching-service/internal/repository"
+	"matching-service/internal/tracing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+var testOptions = Options{
+	Relaxation:     RelaxationPolicy{TopicsAfter: 30 * time.Second, DifficultyAfter: 60 * time.Second},
+	MaxQueueWait:   10 * time.Minute,
+	HeartbeatGrace: 30 * time.Second,
+}
+
+func newTestService(t *testing.T) (*MatchingService, *miniredis.Miniredis) {
+	t.Helper()
+	return newTestServiceWithOptions(t, testOptions)
+}
+
+func newTestServiceWithOptions(t *testing.T, opts Options) (*MatchingService, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	return newTestReplica(t, mr, opts), mr
+}
+
+// newTestReplica starts a service on mr, so several replicas can share one Redis
+func newTestReplica(t *testing.T, mr *miniredis.Miniredis, opts Options) *MatchingService {
+	t.Helper()
+	userSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(repository.CompletedQuestionsResponse{Data: []string{}})
+	}))
+	t.Cleanup(userSrv.Close)
+
+	questionSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode([]repository.Question{{ID: "q1", Title: "Two Sum"}})
+	}))
+	t.Cleanup(questionSrv.Close)
+
+	redisClient := repository.NewRedisClient(mr.Addr())
+	redisClient.AddHook(tracing.RedisHook{})
+	t.Cleanup(func() { _ = redisClient.Close() })
+
+	history := repository.NewMemoryMatchHistoryRepository()
+
+	return NewMatchingService(
+		repository.NewMatchRepository(redisClient),
+		repository.NewUserRepository(userSrv.URL, repository.NewOutboundClient(repository.OutboundOptions{Name: "user-service"})),
+		repository.NewQuestionRepository(questionSrv.URL, repository.NewOutboundClient(repository.OutboundOptions{Name: "question-service"})),
+		history,
+		opts,
+	)
+}
+
+// seedQueue places a live user directly into a queue as if they had enqueued at enqueuedAt
+func seedQueue(t *testing.T, mr *miniredis.Miniredis, queueKey, userID string, enqueuedAt time.Time) {
+	t.Helper()
+	if _, err := mr.ZAdd(queueKey, float64(enqueuedAt.Unix()), userID); err != nil {
+		t.Fatalf("seeding queue: %v", err)
+	}
+	if _, err := mr.SAdd(constants.ActiveQueuesKey, queueKey); err != nil {
+		t.Fatalf("indexing queue: %v", err)
+	}
+	if err := mr.Set("user:"+userID+":alive", "1"); err != nil {
+		t.Fatalf("seeding liveness: %v", err)
+	}
+}
+
+func TestRequestMatchConcurrentNoDoubleMatch(t *testing.T) {
+	service, mr := newTestService(t)
+	ctx := context.Background()
+
+	const numUsers = 50
+	var wg sync.WaitGroup
+	errs := make(chan error, numUsers)
+	for i := 0; i < numUsers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := models.MatchRequest{
+				Topics:     []string{"array"},
+				Difficulty: "easy",
+				UserID:     fmt.Sprintf("u%d", i),
+			}
+			if _, err := service.RequestMatch(ctx, req); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("RequestMatch returned error: %v", err)
+	}
+
+	_, queueKey := buildQueueKey([]string{"array"}, "easy")
+	queued := map[string]bool{}
+	if mr.Exists(queueKey) {
+		members, err := mr.ZMembers(queueKey)
+		if err != nil {
+			t.Fatalf("reading queue: %v", err)
+		}
+		for _, m := range members {
+			queued[m] = true
+		}
+	}
+
+	matchUsers := map[string][]string{}
+	for i := 0; i < numUsers; i++ {
+		userID := fmt.Sprintf("u%d", i)
+		matchID, err := service.CheckUserMatch(ctx, userID)
+		if err != nil {
+			if !queued[userID] {
+				t.Fatalf("user %s was neither matched nor queued", userID)
+			}
+			continue
+		}
+		if queued[userID] {
+			t.Fatalf("user %s is both matched (%s) and still queued", userID, matchID)
+		}
+		matchUsers[matchID] = append(matchUsers[matchID], userID)
+	}
+
+	for matchID, users := range matchUsers {
+		if len(users) != 2 {
+			t.Fatalf("match %s has %d users mapped to it, want 2: %v", matchID, len(users), users)
+		}
+	}
+	if got := 2*len(matchUsers) + len(queued); got != numUsers {
+		t.Fatalf("accounted for %d users, want %d", got, numUsers)
+	}
+}
+
+func TestRequestMatchRelaxesTopicsForLongWaiter(t *testing.T) {
+	service, mr := newTestService(t)
+	ctx := context.Background()
+
+	// u1 has been waiting longer than the topic threshold for array+graph
+	_, waitingKey := buildQueueKey([]string{"array", "graph"}, "easy")
+	seedQueue(t, mr, waitingKey, "u1", time.Now().Add(-45*time.Second))
+
+	res, err := service.RequestMatch(ctx, models.MatchRequest{Topics: []string{"array"}, Difficulty: "easy", UserID: "u2"})
+	if err != nil {
+		t.Fatalf("RequestMatch returned error: %v", err)
+	}
+	if res.Status != "matched" {
+		t.Fatalf("status = %q, want matched", res.Status)
+	}
+	if len(res.Relaxed) != 1 || res.Relaxed[0] != RelaxedTopics {
+		t.Fatalf("relaxed = %v, want [%s]", res.Relaxed, RelaxedTopics)
+	}
+	if mr.Exists(waitingKey) {
+		t.Fatalf("u1 should have been removed from %s", waitingKey)
+	}
+}
+
+func TestRelaxedSearchLeavesOtherQueuesToTheMatchmaker(t *testing.T) {
+	service, mr := newTestService(t)
+	ctx := context.Background()
+
+	// The only relaxed candidate has waited past the queue limit
+	_, expiredKey := buildQueueKey([]string{"array", "graph"}, "easy")
+	seedQueue(t, mr, expiredKey, "ghost", time.Now().Add(-11*time.Minute))
+
+	res, err := service.RequestMatch(ctx, models.MatchRequest{Topics: []string{"array"}, Difficulty: "easy", UserID: "u1"})
+	if err != nil {
+		t.Fatalf("RequestMatch returned error: %v", err)
+	}
+	if res.Status != "waiting" {
+		t.Fatalf("u1 was paired with an expired entry: %+v", res)
+	}
+	if score, _ := mr.ZScore(expiredKey, "ghost"); score == 0 {
+		t.Fatal("the request evicted another queue; that is the matchmaker's job")
+	}
+
+	if _, err := service.RunMatchmakingPass(ctx); err != nil {
+		t.Fatalf("RunMatchmakingPass returned error: %v", err)
+	}
+	if mr.Exists(expiredKey) {
+		t.Fatalf("the matchmaker should have evicted ghost from %s", expiredKey)
+	}
+}
+
+func TestExpiredQueueEntryReportsTimeout(t *testing.T) {
+	service, mr := newTestService(t)
+	ctx := context.Background()
+
+	if _, err := service.RequestMatch(ctx, models.MatchRequest{Topics: []string{"array"}, Difficulty: "easy", UserID: "ghost"}); err != nil {
+		t.Fatalf("RequestMatch returned error: %v", err)
+	}
+	// Pretend the ghost enqueued longer ago than the max wait
+	_, queueKey := buildQueueKey([]string{"array"}, "easy")
+	if _, err := mr.ZAdd(queueKey, float64(time.Now().Add(-11*time.Minute).Unix()), "ghost"); err != nil {
+		t.Fatalf("backdating queue entry: %v", err)
+	}
+
+	res, err := service.RequestMatch(ctx, models.MatchRequest{Topics: []string{"array"}, Difficulty: "easy", UserID: "live"})
+	if err != nil {
+		t.Fatalf("RequestMatch returned error: %v", err)
+	}
+	if res.Status != "waiting" {
+		t.Fatalf("live user was paired with an expired entry: %+v", res)
+	}
+
+	status, details, err := service.CheckUserStatus(ctx, "ghost")
+	if err != nil {
+		t.Fatalf("CheckUserStatus returned error: %v", err)
+	}
+	if status != UserStatusTimedOut || details["queue"] != queueKey {
+		t.Fatalf("status = %d %v, want timeout for %s", status, details, queueKey)
+	}
+}
+
+func TestMissedHeartbeatRemovesUserBeforePairing(t *testing.T) {
+	service, mr := newTestService(t)
+	ctx := context.Background()
+
+	if _, err := service.RequestMatch(ctx, models.MatchRequest{Topics: []string{"array"}, Difficulty: "easy", UserID: "closed-tab"}); err != nil {
+		t.Fatalf("RequestMatch returned error: %v", err)
+	}
+	mr.FastForward(20 * time.Second)
+	if ok, err := service.Heartbeat(ctx, "closed-tab"); err != nil || !ok {
+		t.Fatalf("Heartbeat = %v, %v; want true while waiting", ok, err)
+	}
+	// No more heartbeats: the liveness key lapses after the grace window
+	mr.FastForward(31 * time.Second)
+
+	res, err := service.RequestMatch(ctx, models.MatchRequest{Topics: []string{"array"}, Difficulty: "easy", UserID: "live"})
+	if err != nil {
+		t.Fatalf("RequestMatch returned error: %v", err)
+	}
+	if res.Status != "waiting" {
+		t.Fatalf("live user was paired with a user who stopped heartbeating: %+v", res)
+	}
+	if status, _, _ := service.CheckUserStatus(ctx, "closed-tab"); status != UserStatusTimedOut {
+		t.Fatalf("status = %d, want %d", status, UserStatusTimedOut)
+	}
+	if ok, _ := service.Heartbeat(ctx, "closed-tab"); ok {
+		t.Fatal("Heartbeat should report false once the user has been evicted")
+	}
+}
+
+func TestCancelByUserReleasesBothUsersAndRequeuesPartner(t *testing.T) {
+	opts := testOptions
+	opts.RequeuePartnerOnCancel = true
+	service, mr := newTestServiceWithOptions(t, opts)
+	ctx := context.Background()
+
+	_, queueKey := buildQueueKey([]string{"array"}, "easy")
+	enqueuedAt := time.Now().Add(-time.Minute)
+	seedQueue(t, mr, queueKey, "u1", enqueuedAt)
+	res, err := service.RequestMatch(ctx, models.MatchRequest{Topics: []string{"array"}, Difficulty: "easy", UserID: "u2"})
+	if err != nil || res.Status != "matched" {
+		t.Fatalf("RequestMatch = %+v, %v; want matched", res, err)
+	}
+
+	state, _, err := service.CancelByUser(ctx, "u2")
+	if err != nil || state != "cancelled_matched" {
+		t.Fatalf("CancelByUser = %q, %v; want cancelled_matched", state, err)
+	}
+
+	for _, userID := range []string{"u1", "u2"} {
+		if matchID, err := service.CheckUserMatch(ctx, userID); err == nil {
+			t.Fatalf("%s still maps to match %s", userID, matchID)
+		}
+	}
+	score, err := mr.ZScore(queueKey, "u1")
+	if err != nil || score != float64(enqueuedAt.Unix()) {
+		t.Fatalf("u1 score = %v (%v), want original %d", score, err, enqueuedAt.Unix())
+	}
+	if members, _ := mr.ZMembers(queueKey); len(members) != 1 {
+		t.Fatalf("queue = %v, want only the partner requeued", members)
+	}
+}
+
+func TestCheckMatchStatusReturnsFullRecord(t *testing.T) {
+	service, _ := newTestService(t)
+	ctx := context.Background()
+
+	req := models.MatchRequest{Topics: []string{"array"}, Difficulty: "easy"}
+	req.UserID = "u1"
+	if _, err := service.RequestMatch(ctx, req); err != nil {
+		t.Fatalf("RequestMatch returned error: %v", err)
+	}
+	req.UserID = "u2"
+	res, err := service.RequestMatch(ctx, req)
+	if err != nil || res.MatchID == "" {
+		t.Fatalf("RequestMatch = %+v, %v; want a match", res, err)
+	}
+
+	match, err := service.CheckMatchStatus(ctx, res.MatchID)
+	if err != nil {
+		t.Fatalf("CheckMatchStatus returned error: %v", err)
+	}
+	if len(match.UserIDs) != 2 || match.UserIDs[0] != "u1" || match.UserIDs[1] != "u2" {
+		t.Fatalf("userIds = %v, want [u1 u2]", match.UserIDs)
+	}
+	if match.Difficulty != "easy" || len(match.Topics) != 1 || match.Topics[0] != "array" {
+		t.Fatalf("criteria = %s %v, want easy [array]", match.Difficulty, match.Topics)
+	}
+	if len(match.TopicCoverage) != 1 || match.TopicCoverage[0] != "array" {
+		t.Fatalf("topicCoverage = %v, want [array]", match.TopicCoverage)
+	}
+	if match.QuestionID != "q1" || match.QuestionTitle != "Two Sum" || match.Status != models.MatchStatusMatched {
+		t.Fatalf("match = %+v, want question q1 \"Two Sum\" and status matched", match)
+	}
+	if match.CreatedAt == nil || match.ExpiresAt == nil || !match.ExpiresAt.After(*match.CreatedAt) {
+		t.Fatalf("timestamps = %v..%v, want expiry after creation", match.CreatedAt, match.ExpiresAt)
+	}
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"matching-service/internal/constants"
+)
+
+// MatchmakerWorker periodically pairs queued users independently of incoming requests,
+// so pairs left behind by failed or interrupted requests are still matched.
+// A Redis lease ensures only one replica runs a pass at a time. While question-service is
+// unavailable, passes back off exponentially up to maxMatchmakerBackoff.
+type MatchmakerWorker struct {
+	service  *MatchingService
+	interval time.Duration
+	leaseTTL time.Duration
+	owner    string
+
+	backoff   time.Duration
+	idleUntil time.Time
+}
+
+const maxMatchmakerBackoff = time.Minute
+
+func NewMatchmakerWorker(service *MatchingService, interval time.Duration) *MatchmakerWorker {
+	hostname, _ := os.Hostname()
+	return &MatchmakerWorker{
+		service:  service,
+		interval: interval,
+		// Outlive a couple of missed ticks so a healthy holder keeps the lease
+		leaseTTL: 3 * interval,
+		owner:    fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), time.Now().UnixNano()),
+	}
+}
+
+// Run ticks until ctx is cancelled, then releases the lease if held.
+func (w *MatchmakerWorker) Run(ctx context.Context) {
+	if w.interval <= 0 {
+		slog.Info("matchmaker worker disabled")
+		return
+	}
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	defer w.release()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.tick(ctx)
+		}
+	}
+}
+
+func (w *MatchmakerWorker) tick(ctx context.Context) {
+	if time.Now().Before(w.idleUntil) {
+		return
+	}
+	held, err := w.service.repo.AcquireLease(ctx, constants.MatchmakerLeaseKey, w.owner, w.leaseTTL)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.ErrorContext(ctx, "matchmaker failed to acquire lease", "error", err)
+		}
+		return
+	}
+	if !held {
+		return
+	}
+	// Claims are atomic, so a pass overrunning the lease cannot double-match users
+	matched, err := w.service.RunMatchmakingPass(ctx)
+	switch {
+	case errors.Is(err, ErrQuestionsUnavailable):
+		// Queued pairs are kept; wait for question-service to recover before trying them again
+		w.backoff = min(max(2*w.backoff, w.interval), maxMatchmakerBackoff)
+		w.idleUntil = time.Now().Add(w.backoff)
+		slog.WarnContext(ctx, "matchmaker backing off while question service is unavailable", "backoff_ms", w.backoff.Milliseconds(), "error", err)
+	case err != nil && ctx.Err() == nil:
+		slog.ErrorContext(ctx, "matchmaker pass failed", "error", err)
+	default:
+		w.backoff = 0
+	}
+	if matched > 0 {
+		slog.InfoContext(ctx, "matchmaker formed matches", "matches", matched)
+	}
+}
+
+func (w *MatchmakerWorker) release() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := w.service.repo.ReleaseLease(ctx, constants.MatchmakerLeaseKey, w.owner); err != nil {
+		slog.Error("matchmaker failed to release lease", "error", err)
+	}
+}
+
+// RunMatchmakingPass expires overdue handshakes, scans every queue, evicts expired entries, pairs users with identical criteria and then
+// tries relaxed partners for anyone left alone in their queue. It returns the number
+// of matches formed.
+func (s *MatchingService) RunMatchmakingPass(ctx context.Context) (int, error) {
+	// Release users from handshakes that were never completed first, so they can be paired again
+	if err := s.ExpireOverdueHandshakes(ctx); err != nil {
+		return 0, err
+	}
+
+	queues, err := s.repo.GetAllQueues(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	matched := 0
+	for _, q := range queues {
+		difficulty, topics, ok := parseQueueKey(q.Key)
+		if !ok {
+			continue
+		}
+		if err := s.evictStale(ctx, q.Key); err != nil {
+			return matched, err
+		}
+		for ctx.Err() == nil {
+			users, err := s.repo.PeekTwo(ctx, q.Key)
+			if err != nil {
+				return matched, err
+			}
+			if len(users) < 2 {
+				break
+			}
+			p := &pairing{
+				users:      users,
+				queueKeys:  []string{q.Key, q.Key},
+				topics:     topics,
+				difficulty: difficulty,
+			}
+			res, claimed, err := s.formMatch(ctx, p)
+			if err != nil {
+				return matched, err
+			}
+			if claimed && res.MatchID != "" {
+				matched++
+			}
+		}
+
+		// Whoever is left alone may be eligible for a relaxed match by now
+		userID, enqueuedAt, err := s.repo.PeekOldest(ctx, q.Key)
+		if err != nil {
+			return matched, err
+		}
+		if userID == "" {
+			continue
+		}
+		self := queuedUser{userID: userID, queueKey: q.Key, difficulty: difficulty, topics: topics, enqueuedAt: enqueuedAt}
+		p, err := s.findRelaxedPartner(ctx, self)
+		if err != nil {
+			return matched, err
+		}
+		if p == nil {
+			continue
+		}
+		res, claimed, err := s.formMatch(ctx, p)
+		if err != nil {
+			return matched, err
+		}
+		if claimed && res.MatchID != "" {
+			matched++
+		}
+	}
+	return matched, ctx.Err()
+}
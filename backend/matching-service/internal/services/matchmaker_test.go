@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"matching-service/internal/constants"
+	"matching-service/internal/models"
+	"matching-service/internal/repository"
+)
+
+func TestRunMatchmakingPassPairsStrandedUsers(t *testing.T) {
+	service, mr := newTestService(t)
+	ctx := context.Background()
+
+	// Users left in the queue, e.g. by a request that failed after Enqueue
+	_, queueKey := buildQueueKey([]string{"array"}, "easy")
+	for _, userID := range []string{"u1", "u2", "u3"} {
+		seedQueue(t, mr, queueKey, userID, time.Now())
+	}
+
+	matched, err := service.RunMatchmakingPass(ctx)
+	if err != nil {
+		t.Fatalf("RunMatchmakingPass returned error: %v", err)
+	}
+	if matched != 1 {
+		t.Fatalf("matched = %d, want 1", matched)
+	}
+	members, _ := mr.ZMembers(queueKey)
+	if len(members) != 1 {
+		t.Fatalf("queue has %v, want exactly one user left", members)
+	}
+}
+
+func TestMatchmakerLeaseIsExclusive(t *testing.T) {
+	service, _ := newTestService(t)
+	ctx := context.Background()
+
+	first := NewMatchmakerWorker(service, time.Second)
+	second := NewMatchmakerWorker(service, time.Second)
+
+	if held, err := service.repo.AcquireLease(ctx, constants.MatchmakerLeaseKey, first.owner, first.leaseTTL); err != nil || !held {
+		t.Fatalf("first worker should acquire the lease, held=%v err=%v", held, err)
+	}
+	if held, _ := service.repo.AcquireLease(ctx, constants.MatchmakerLeaseKey, second.owner, second.leaseTTL); held {
+		t.Fatal("second worker acquired a lease that is already held")
+	}
+
+	first.release()
+	if held, _ := service.repo.AcquireLease(ctx, constants.MatchmakerLeaseKey, second.owner, second.leaseTTL); !held {
+		t.Fatal("second worker should acquire the lease once it is released")
+	}
+}
+
+func TestRunMatchmakingPassKeepsPairQueuedWhileQuestionsUnavailable(t *testing.T) {
+	service, f := newFakeService(t, Options{})
+	ctx := context.Background()
+	queueKey := f.queueUser(t, "u1", []string{"array"}, "easy", time.Now().Add(-time.Second))
+	f.queueUser(t, "u2", []string{"array"}, "easy", time.Now())
+	events := subscribe(t, service, "u1")
+
+	f.questions.Err = errors.New("connection refused")
+	if _, err := service.RunMatchmakingPass(ctx); !errors.Is(err, ErrQuestionsUnavailable) {
+		t.Fatalf("RunMatchmakingPass error = %v, want ErrQuestionsUnavailable", err)
+	}
+	if users, _ := f.store.PeekTwo(ctx, queueKey); len(users) != 2 {
+		t.Fatalf("queued users = %v, want the pair kept while question-service is down", users)
+	}
+
+	f.questions.Err = nil
+	matched, err := service.RunMatchmakingPass(ctx)
+	if err != nil || matched != 1 {
+		t.Fatalf("pass after recovery = %d, %v; want the pair matched", matched, err)
+	}
+	if event := nextEvent(t, events, models.EventMatched); event.PartnerID != "u2" {
+		t.Fatalf("event = %+v, want a match with u2", event)
+	}
+}
+
+func TestRunMatchmakingPassDropsPairWithoutQuestion(t *testing.T) {
+	service, f := newFakeService(t, Options{})
+	ctx := context.Background()
+	queueKey := f.queueUser(t, "u1", []string{"graph"}, "hard", time.Now().Add(-time.Second))
+	f.queueUser(t, "u2", []string{"graph"}, "hard", time.Now())
+	events := map[string]<-chan models.MatchEvent{"u1": subscribe(t, service, "u1"), "u2": subscribe(t, service, "u2")}
+
+	if _, err := service.RunMatchmakingPass(ctx); err != nil {
+		t.Fatalf("RunMatchmakingPass returned error: %v", err)
+	}
+	if users, _ := f.store.GetQueueMembers(ctx, queueKey); len(users) != 0 {
+		t.Fatalf("queued users = %v, want the pair dropped", users)
+	}
+	for userID, ch := range events {
+		event := nextEvent(t, ch, models.EventCancelled)
+		if event.Reason != models.ReasonNoSuitableQuestion || event.Queue != queueKey {
+			t.Fatalf("%s event = %+v, want cancelled for want of a question", userID, event)
+		}
+		if status, _, _ := service.CheckUserStatus(ctx, userID); status != UserStatusNone {
+			t.Fatalf("%s status = %d, want their queue mapping cleared", userID, status)
+		}
+	}
+}
+
+func TestRunMatchmakingPassOnlyRetriesWhenQuestionServiceIsDown(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     int
+		body       string
+		wantQueued bool
+	}{
+		{name: "bad request", status: http.StatusBadRequest, body: `{"error":"invalid tag"}`},
+		{name: "not found", status: http.StatusNotFound},
+		{name: "undecodable body", status: http.StatusOK, body: "<html>"},
+		{name: "server error", status: http.StatusServiceUnavailable, wantQueued: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			t.Cleanup(srv.Close)
+			questions := repository.NewQuestionRepository(srv.URL, repository.NewOutboundClient(repository.OutboundOptions{Name: "question-service"}))
+			f := fakes{store: repository.NewMemoryMatchStore(), users: repository.NewMemoryUserLookup()}
+			service := NewMatchingService(f.store, f.users, questions, repository.NewMemoryMatchHistoryRepository(), Options{})
+			ctx := context.Background()
+			queueKey := f.queueUser(t, "u1", []string{"array"}, "easy", time.Now().Add(-time.Second))
+			f.queueUser(t, "u2", []string{"array"}, "easy", time.Now())
+
+			_, err := service.RunMatchmakingPass(ctx)
+			users, _ := f.store.GetQueueMembers(ctx, queueKey)
+			if tt.wantQueued {
+				if !errors.Is(err, ErrQuestionsUnavailable) || len(users) != 2 {
+					t.Fatalf("pass = %v leaving %v queued, want the pair kept while question-service is down", err, users)
+				}
+				return
+			}
+			if err != nil || len(users) != 0 {
+				t.Fatalf("pass = %v leaving %v queued, want the pair dropped: question-service answered", err, users)
+			}
+		})
+	}
+}
+
+func TestMatchmakerBacksOffWhileQuestionsUnavailable(t *testing.T) {
+	service, f := newFakeService(t, Options{})
+	ctx := context.Background()
+	f.queueUser(t, "u1", []string{"array"}, "easy", time.Now().Add(-time.Second))
+	f.queueUser(t, "u2", []string{"array"}, "easy", time.Now())
+	f.questions.Err = errors.New("connection refused")
+
+	worker := NewMatchmakerWorker(service, time.Second)
+	worker.tick(ctx)
+	if worker.backoff != time.Second || !worker.idleUntil.After(time.Now()) {
+		t.Fatalf("backoff = %v until %v, want a second's pause", worker.backoff, worker.idleUntil)
+	}
+	for range 10 {
+		worker.idleUntil = time.Time{}
+		worker.tick(ctx)
+	}
+	if worker.backoff != maxMatchmakerBackoff {
+		t.Fatalf("backoff = %v, want it capped at %v", worker.backoff, maxMatchmakerBackoff)
+	}
+
+	f.questions.Err = nil
+	worker.idleUntil = time.Time{}
+	worker.tick(ctx)
+	if worker.backoff != 0 {
+		t.Fatalf("backoff = %v after recovery, want it reset", worker.backoff)
+	}
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+
+	"matching-service/internal/repository"
+	"matching-service/internal/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrQuestionsUnavailable means question-service could not be asked for questions, as
+// opposed to having none that suit the pair. Pairs stay queued until it recovers.
+var ErrQuestionsUnavailable = errors.New("question service unavailable")
+
+// questionSampleSizes are the progressively larger samples requested per topic
+var questionSampleSizes = []int{10, 50, 100}
+
+// questionChoice is a question, fetched as a candidate or picked for a pair, and the
+// requested topics it covers
+type questionChoice struct {
+	question repository.Question
+	coverage []string
+}
+
+// selectQuestion picks a question for the pair across all requested topics. Candidates are
+// fetched for every topic concurrently, with progressive sampling. Questions neither user has
+// completed win, then questions only one of them has completed. Within those, questions
+// tagged with more of the requested topics win, and remaining ties go to the topic whose turn
+// it is, so repeated matches on the same topics rotate between them.
+//
+// All calls to other services share the match budget; once it runs out the remaining
+// fetches fail and the best question found so far, if any, is used. It returns
+// ErrNoSuitableQuestion if question-service answered but nothing suits the pair, and
+// ErrQuestionsUnavailable if it never answered.
+func (s *MatchingService) selectQuestion(ctx context.Context, user1ID, user2ID string, topics []string, difficulty string) (choice *questionChoice, err error) {
+	ctx, span := tracing.Start(ctx, "MatchingService.selectQuestion", trace.WithAttributes(
+		attribute.String("match.difficulty", difficulty),
+		attribute.StringSlice("match.topics", topics),
+	))
+	defer func() {
+		if choice != nil {
+			span.SetAttributes(attribute.String("question.id", choice.question.ID), attribute.StringSlice("question.topic_coverage", choice.coverage))
+		}
+		tracing.End(span, err)
+	}()
+
+	if len(topics) == 0 {
+		return nil, fmt.Errorf("%w: no topics", ErrNoSuitableQuestion)
+	}
+	if s.matchBudget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.matchBudget)
+		defer cancel()
+	}
+
+	// Fetch completed questions for both users; if that fails, proceed without filtering
+	completed := make(map[string]int)
+	for _, userID := range []string{user1ID, user2ID} {
+		ids, err := s.userRepo.GetCompletedQuestions(ctx, userID)
+		if err != nil {
+			continue
+		}
+		seen := make(map[string]bool, len(ids))
+		for _, id := range ids {
+			if !seen[id] {
+				seen[id] = true
+				completed[id]++
+			}
+		}
+	}
+
+	ordered := s.rotateTopics(topics)
+	var lastCandidates []questionChoice
+	var fetchErr error
+	answered := false
+	for _, size := range questionSampleSizes {
+		candidates, err := s.fetchCandidates(ctx, ordered, topics, difficulty, size)
+		if err != nil {
+			fetchErr = err
+			continue // Every topic failed; try the next sample size
+		}
+		answered = true
+		lastCandidates = candidates
+		// Prefer a question neither user has completed
+		if c := bestCandidate(candidates, func(id string) bool { return completed[id] == 0 }); c != nil {
+			return c, nil
+		}
+	}
+
+	// Fallback: allow questions only one of the users has completed
+	if c := bestCandidate(lastCandidates, func(id string) bool { return completed[id] < 2 }); c != nil {
+		return c, nil
+	}
+
+	if !answered {
+		return nil, fmt.Errorf("%w: %w", ErrQuestionsUnavailable, fetchErr)
+	}
+	// Final fallback: return "no_suitable_question" status
+	return nil, ErrNoSuitableQuestion
+}
+
+// rotateTopics returns the topics starting at the next one in turn
+func (s *MatchingService) rotateTopics(topics []string) []string {
+	offset := int(s.topicRotation.Add(1)-1) % len(topics)
+	return append(append([]string{}, topics[offset:]...), topics[:offset]...)
+}
+
+// fetchCandidates queries question-service for every topic concurrently and merges the results,
+// in topic order and without duplicates. Coverage is reported against the requested topics.
+// It only fails if no query reached question-service: a topic it refused or answered with an
+// unusable body just contributes no candidates.
+func (s *MatchingService) fetchCandidates(ctx context.Context, topics, requested []string, difficulty string, size int) ([]questionChoice, error) {
+	results := make([][]repository.Question, len(topics))
+	errs := make([]error, len(topics))
+	var wg sync.WaitGroup
+	for i, topic := range topics {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = s.questionRepo.GetQuestionsByDifficultyAndTag(ctx, difficulty, topic, size)
+		}()
+	}
+	wg.Wait()
+
+	// question-service only returns the tags a question matched on, so a question returned
+	// for several topics collects its coverage from each of those queries
+	ok := false
+	index := make(map[string]int)
+	var candidates []questionChoice
+	var covered []map[string]bool
+	for i, questions := range results {
+		if errs[i] != nil {
+			// A refused or garbled answer still means question-service is up; it just
+			// has nothing usable for this topic
+			if errors.Is(errs[i], repository.ErrUnusableResponse) {
+				ok = true
+				slog.WarnContext(ctx, "question service gave an unusable answer", "topic", topics[i], "error", errs[i])
+			}
+			continue
+		}
+		ok = true
+		for _, q := range questions {
+			j, seen := index[q.ID]
+			if !seen {
+				j = len(candidates)
+				index[q.ID] = j
+				candidates = append(candidates, questionChoice{question: q})
+				covered = append(covered, make(map[string]bool))
+			}
+			covered[j][topics[i]] = true
+			for _, topic := range requested {
+				if hasTopicTag(q, topic) {
+					covered[j][topic] = true
+				}
+			}
+		}
+	}
+	if !ok {
+		return nil, errors.Join(errs...)
+	}
+	for j := range candidates {
+		for _, topic := range requested {
+			if covered[j][topic] {
+				candidates[j].coverage = append(candidates[j].coverage, topic)
+			}
+		}
+	}
+	return candidates, nil
+}
+
+func hasTopicTag(q repository.Question, topic string) bool {
+	for _, tag := range q.TopicTags {
+		if strings.EqualFold(tag.Slug, topic) || strings.EqualFold(tag.Name, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// bestCandidate returns the allowed candidate covering the most topics; earlier candidates win ties
+func bestCandidate(candidates []questionChoice, allowed func(id string) bool) *questionChoice {
+	var allowedCandidates []questionChoice
+	for _, c := range candidates {
+		if allowed(c.question.ID) {
+			allowedCandidates = append(allowedCandidates, c)
+		}
+	}
+	if len(allowedCandidates) == 0 {
+		return nil
+	}
+	sort.SliceStable(allowedCandidates, func(i, j int) bool {
+		return len(allowedCandidates[i].coverage) > len(allowedCandidates[j].coverage)
+	})
+	return &allowedCandidates[0]
+}
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"matching-service/internal/constants"
+)
+
+// Criteria that can be relaxed when forming a match
+const (
+	RelaxedTopics     = "topics"
+	RelaxedDifficulty = "difficulty"
+)
+
+// RelaxationPolicy widens the match criteria the longer a user has been waiting.
+// A pair is judged by whichever of the two users has waited longer.
+type RelaxationPolicy struct {
+	// TopicsAfter allows matching anyone who shares at least one topic
+	TopicsAfter time.Duration
+	// DifficultyAfter additionally allows matching an adjacent difficulty
+	DifficultyAfter time.Duration
+}
+
+// queuedUser is a user waiting in a specific queue
+type queuedUser struct {
+	userID     string
+	queueKey   string
+	difficulty string
+	topics     []string
+	enqueuedAt time.Time
+}
+
+// parseQueueKey splits "queue:difficulty:topic1,topic2" into its difficulty and topics
+func parseQueueKey(queueKey string) (difficulty string, topics []string, ok bool) {
+	parts := strings.Split(queueKey, constants.QueueKeyDelimiter)
+	if len(parts) != constants.QueueKeyParts || parts[0] != constants.QueueKeyPrefix {
+		return "", nil, false
+	}
+	return parts[1], strings.Split(parts[2], ","), true
+}
+
+// difficultyIndex ranks a difficulty from easiest; neighbours in constants.Difficulties are "adjacent"
+func difficultyIndex(difficulty string) int {
+	for i, d := range constants.Difficulties {
+		if string(d) == difficulty {
+			return i
+		}
+	}
+	return -1
+}
+
+func adjacentDifficulty(a, b string) bool {
+	i, j := difficultyIndex(a), difficultyIndex(b)
+	if i < 0 || j < 0 {
+		return false
+	}
+	return i-j == 1 || j-i == 1
+}
+
+// sharedTopics returns the topics present in both lists, in the order of a
+func sharedTopics(a, b []string) []string {
+	inB := make(map[string]bool, len(b))
+	for _, t := range b {
+		inB[t] = true
+	}
+	var shared []string
+	for _, t := range a {
+		if inB[t] {
+			shared = append(shared, t)
+		}
+	}
+	return shared
+}
+
+// relax reports whether a and b may be paired at time now and which criteria had to be relaxed
+func (p RelaxationPolicy) relax(a, b queuedUser, now time.Time) (relaxed []string, ok bool) {
+	earliest := a.enqueuedAt
+	if b.enqueuedAt.Before(earliest) {
+		earliest = b.enqueuedAt
+	}
+	waited := now.Sub(earliest)
+
+	shared := sharedTopics(a.topics, b.topics)
+	if len(shared) == 0 {
+		return nil, false
+	}
+	if len(shared) != len(a.topics) || len(shared) != len(b.topics) {
+		if waited < p.TopicsAfter {
+			return nil, false
+		}
+		relaxed = append(relaxed, RelaxedTopics)
+	}
+	if a.difficulty != b.difficulty {
+		if !adjacentDifficulty(a.difficulty, b.difficulty) || waited < p.DifficultyAfter {
+			return nil, false
+		}
+		relaxed = append(relaxed, RelaxedDifficulty)
+	}
+	return relaxed, true
+}
+
+// findRelaxedPartner looks through the other queues for the best partner for self under the
+// relaxation policy. Candidates needing fewer relaxations win, then the longest-waiting one.
+// Other queues are not evicted here, as that would touch every queue on each request; the
+// matchmaker worker evicts them, and anyone already past the queue wait limit is skipped.
+func (s *MatchingService) findRelaxedPartner(ctx context.Context, self queuedUser) (*pairing, error) {
+	queues, err := s.repo.GetAllQueues(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var best *pairing
+	var bestEnqueuedAt time.Time
+	for _, q := range queues {
+		if q.Key == self.queueKey || q.Size == 0 {
+			continue
+		}
+		difficulty, topics, ok := parseQueueKey(q.Key)
+		if !ok {
+			continue
+		}
+		userID, enqueuedAt, err := s.repo.PeekOldest(ctx, q.Key)
+		if err != nil || userID == "" || userID == self.userID {
+			continue
+		}
+		if s.maxQueueWait > 0 && !enqueuedAt.After(now.Add(-s.maxQueueWait)) {
+			continue
+		}
+		other := queuedUser{userID: userID, queueKey: q.Key, difficulty: difficulty, topics: topics, enqueuedAt: enqueuedAt}
+		relaxed, ok := s.relaxation.relax(self, other, now)
+		if !ok {
+			continue
+		}
+		if best != nil {
+			if len(relaxed) > len(best.relaxed) {
+				continue
+			}
+			if len(relaxed) == len(best.relaxed) && !enqueuedAt.Before(bestEnqueuedAt) {
+				continue
+			}
+		}
+		best = newRelaxedPairing(self, other, relaxed)
+		bestEnqueuedAt = enqueuedAt
+	}
+	return best, nil
+}
+
+// newRelaxedPairing pairs two users from different queues. The question uses the shared
+// topics and the difficulty of the more recent user, since the longer waiter relaxed.
+func newRelaxedPairing(a, b queuedUser, relaxed []string) *pairing {
+	older, newer := a, b
+	if newer.enqueuedAt.Before(older.enqueuedAt) {
+		older, newer = newer, older
+	}
+	return &pairing{
+		users:      []string{older.userID, newer.userID},
+		queueKeys:  []string{older.queueKey, newer.queueKey},
+		topics:     sharedTopics(newer.topics, older.topics),
+		difficulty: newer.difficulty,
+		relaxed:    relaxed,
+	}
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRelaxationPolicy(t *testing.T) {
+	policy := RelaxationPolicy{TopicsAfter: 30 * time.Second, DifficultyAfter: 60 * time.Second}
+	now := time.Now()
+
+	tests := []struct {
+		name        string
+		a, b        queuedUser
+		wantOK      bool
+		wantRelaxed []string
+	}{
+		{
+			name:   "identical criteria",
+			a:      queuedUser{difficulty: "easy", topics: []string{"array"}, enqueuedAt: now},
+			b:      queuedUser{difficulty: "easy", topics: []string{"array"}, enqueuedAt: now},
+			wantOK: true,
+		},
+		{
+			name:   "overlapping topics before threshold",
+			a:      queuedUser{difficulty: "easy", topics: []string{"array", "graph"}, enqueuedAt: now.Add(-10 * time.Second)},
+			b:      queuedUser{difficulty: "easy", topics: []string{"array"}, enqueuedAt: now},
+			wantOK: false,
+		},
+		{
+			name:        "overlapping topics after threshold",
+			a:           queuedUser{difficulty: "easy", topics: []string{"array", "graph"}, enqueuedAt: now.Add(-40 * time.Second)},
+			b:           queuedUser{difficulty: "easy", topics: []string{"array"}, enqueuedAt: now},
+			wantOK:      true,
+			wantRelaxed: []string{RelaxedTopics},
+		},
+		{
+			name:   "disjoint topics never match",
+			a:      queuedUser{difficulty: "easy", topics: []string{"graph"}, enqueuedAt: now.Add(-time.Hour)},
+			b:      queuedUser{difficulty: "easy", topics: []string{"array"}, enqueuedAt: now},
+			wantOK: false,
+		},
+		{
+			name:   "adjacent difficulty before threshold",
+			a:      queuedUser{difficulty: "easy", topics: []string{"array"}, enqueuedAt: now.Add(-40 * time.Second)},
+			b:      queuedUser{difficulty: "medium", topics: []string{"array"}, enqueuedAt: now},
+			wantOK: false,
+		},
+		{
+			name:        "adjacent difficulty after threshold",
+			a:           queuedUser{difficulty: "easy", topics: []string{"array", "graph"}, enqueuedAt: now.Add(-90 * time.Second)},
+			b:           queuedUser{difficulty: "medium", topics: []string{"array"}, enqueuedAt: now},
+			wantOK:      true,
+			wantRelaxed: []string{RelaxedTopics, RelaxedDifficulty},
+		},
+		{
+			name:   "non-adjacent difficulty never matches",
+			a:      queuedUser{difficulty: "easy", topics: []string{"array"}, enqueuedAt: now.Add(-time.Hour)},
+			b:      queuedUser{difficulty: "hard", topics: []string{"array"}, enqueuedAt: now},
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			relaxed, ok := policy.relax(tt.a, tt.b, now)
+			if ok != tt.wantOK {
+				t.Fatalf("relax ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !reflect.DeepEqual(relaxed, tt.wantRelaxed) {
+				t.Fatalf("relaxed = %v, want %v", relaxed, tt.wantRelaxed)
+			}
+		})
+	}
+}
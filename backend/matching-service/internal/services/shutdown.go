@@ -0,0 +1,17 @@
+package services
+
+import "errors"
+
+// ErrShuttingDown is returned for new match requests once the service has begun shutting down
+var ErrShuttingDown = errors.New("matching service is shutting down")
+
+// StopAccepting makes RequestMatch reject new requests with ErrShuttingDown. Requests already
+// pairing users run to completion, so a replica can drain before it exits.
+func (s *MatchingService) StopAccepting() {
+	s.stopping.Store(true)
+}
+
+// ShuttingDown reports whether StopAccepting has been called
+func (s *MatchingService) ShuttingDown() bool {
+	return s.stopping.Load()
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"matching-service/internal/models"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs an in-memory exporter as the global tracer provider for the test
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previous)
+		_ = provider.Shutdown(context.Background())
+	})
+	return exporter
+}
+
+func TestRequestMatchSpanTree(t *testing.T) {
+	exporter := recordSpans(t)
+	service, mr := newTestService(t)
+	seedQueue(t, mr, "queue:easy:array", "u1", time.Now())
+
+	res, err := service.RequestMatch(context.Background(), models.MatchRequest{UserID: "u2", Topics: []string{"array"}, Difficulty: "easy"})
+	if err != nil {
+		t.Fatalf("RequestMatch returned error: %v", err)
+	}
+	if res.Status != models.MatchStatusMatched {
+		t.Fatalf("status = %s, want matched", res.Status)
+	}
+
+	spans := exporter.GetSpans()
+	find := func(name string) tracetest.SpanStub {
+		t.Helper()
+		for _, span := range spans {
+			if span.Name == name {
+				return span
+			}
+		}
+		t.Fatalf("no %q span among %d spans", name, len(spans))
+		return tracetest.SpanStub{}
+	}
+	childrenOf := func(parent tracetest.SpanStub, prefix string) int {
+		n := 0
+		for _, span := range spans {
+			if span.Parent.SpanID() == parent.SpanContext.SpanID() && strings.HasPrefix(span.Name, prefix) {
+				n++
+			}
+		}
+		return n
+	}
+
+	root := find("MatchingService.RequestMatch")
+	if root.Parent.IsValid() {
+		t.Fatalf("RequestMatch span has parent %s, want a root span", root.Parent.SpanID())
+	}
+	selection := find("MatchingService.selectQuestion")
+	if selection.Parent.SpanID() != root.SpanContext.SpanID() {
+		t.Fatal("selectQuestion span is not a child of RequestMatch")
+	}
+	if n := childrenOf(root, "redis "); n == 0 {
+		t.Fatal("RequestMatch span has no Redis command spans")
+	}
+	if n := childrenOf(selection, "GET user-service"); n != 2 {
+		t.Fatalf("selectQuestion has %d user-service spans, want one per user", n)
+	}
+	if n := childrenOf(selection, "GET question-service"); n == 0 {
+		t.Fatal("selectQuestion has no question-service spans")
+	}
+	for _, span := range spans {
+		if span.SpanContext.TraceID() != root.SpanContext.TraceID() {
+			t.Fatalf("span %q belongs to another trace", span.Name)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSetupRejectsUnknownExporter(t *testing.T) {
+	if _, err := Setup(context.Background(), Options{Exporter: "zipkin"}); err == nil {
+		t.Fatal("expected an error for an unknown exporter")
+	}
+}
+
+func TestSetupWithoutExporter(t *testing.T) {
+	shutdown, err := Setup(context.Background(), Options{Exporter: ExporterNone, ServiceName: "test"})
+	if err != nil {
+		t.Fatalf("Setup returned error: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("shutdown returned error: %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package validation
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"matching-service/internal/constants"
+	"matching-service/internal/repository"
+)
+
+// Catalogue is the set of difficulties and topics a match request may use. The topics can be
+// replaced at runtime, e.g. when refreshed from question-service.
+type Catalogue struct {
+	mu           sync.RWMutex
+	difficulties map[string]bool
+	topics       map[string]bool
+}
+
+// NewCatalogue builds a catalogue from the given topics, or the default topics when none are
+// given. Topics are normalised the same way requests are.
+func NewCatalogue(topics []string) *Catalogue {
+	c := &Catalogue{difficulties: make(map[string]bool)}
+	for _, d := range constants.Difficulties {
+		c.difficulties[string(d)] = true
+	}
+	if len(topics) == 0 {
+		for _, t := range constants.DefaultTopics {
+			topics = append(topics, string(t))
+		}
+	}
+	c.ReplaceTopics(topics)
+	return c
+}
+
+func (c *Catalogue) HasDifficulty(difficulty string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.difficulties[difficulty]
+}
+
+func (c *Catalogue) HasTopic(topic string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.topics[topic]
+}
+
+// Size returns the number of topics in the catalogue
+func (c *Catalogue) Size() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.topics)
+}
+
+// Topics returns the catalogue's topics in sorted order
+func (c *Catalogue) Topics() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	topics := make([]string, 0, len(c.topics))
+	for t := range c.topics {
+		topics = append(topics, t)
+	}
+	slices.Sort(topics)
+	return topics
+}
+
+// ReplaceTopics swaps in a new topic list. An empty list is ignored so a failed or
+// empty refresh never locks every request out.
+func (c *Catalogue) ReplaceTopics(topics []string) {
+	next := make(map[string]bool, len(topics))
+	for _, t := range topics {
+		if t = NormalizeTopic(t); t != "" {
+			next[t] = true
+		}
+	}
+	if len(next) == 0 {
+		return
+	}
+	c.mu.Lock()
+	c.topics = next
+	c.mu.Unlock()
+}
+
+// TopicSource provides the topics question-service knows about
+type TopicSource interface {
+	GetTopicTags(ctx context.Context) ([]repository.TopicTag, error)
+}
+
+// Refresh replaces the topics with those from source
+func (c *Catalogue) Refresh(ctx context.Context, source TopicSource) error {
+	tags, err := source.GetTopicTags(ctx)
+	if err != nil {
+		return err
+	}
+	topics := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		topics = append(topics, tag.Slug)
+	}
+	c.ReplaceTopics(topics)
+	return nil
+}
+
+// RunRefresh refreshes the topics from source now and then every interval until ctx is
+// cancelled. Failures are logged and the previous topics kept. A non-positive interval
+// disables refreshing.
+func (c *Catalogue) RunRefresh(ctx context.Context, source TopicSource, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := c.Refresh(ctx, source); err != nil {
+			slog.WarnContext(ctx, "failed to refresh topic catalogue", "error", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
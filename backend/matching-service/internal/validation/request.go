@@ -0,0 +1,101 @@
+package validation
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"matching-service/internal/models"
+)
+
+// MaxUserIDLength bounds user IDs, which become part of Redis keys
+const MaxUserIDLength = 128
+
+// FieldError describes one invalid field of a request
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors lists every invalid field of a request
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, fe := range e {
+		parts[i] = fe.Field + ": " + fe.Message
+	}
+	return "invalid request: " + strings.Join(parts, "; ")
+}
+
+// NormalizeTopic lower-cases a topic and joins words with hyphens, so "Hash Table"
+// becomes the slug "hash-table"
+func NormalizeTopic(topic string) string {
+	return strings.Join(strings.Fields(strings.ToLower(topic)), "-")
+}
+
+// NormalizeUserID trims surrounding whitespace. User IDs are case-sensitive.
+func NormalizeUserID(userID string) string {
+	return strings.TrimSpace(userID)
+}
+
+// NormalizeMatchRequest normalises the request in place and checks it against the
+// catalogue. Topics are slugged and de-duplicated, keeping their first occurrence, and a
+// request may select every topic in the catalogue but no more. It returns Errors listing
+// every invalid field, or nil.
+func NormalizeMatchRequest(req *models.MatchRequest, catalogue *Catalogue) error {
+	req.UserID = NormalizeUserID(req.UserID)
+	req.Difficulty = strings.ToLower(strings.TrimSpace(req.Difficulty))
+
+	seen := make(map[string]bool, len(req.Topics))
+	topics := make([]string, 0, len(req.Topics))
+	for _, t := range req.Topics {
+		t = NormalizeTopic(t)
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		topics = append(topics, t)
+	}
+	req.Topics = topics
+
+	var errs Errors
+	errs = append(errs, validateUserID("userId", req.UserID)...)
+
+	switch {
+	case req.Difficulty == "":
+		errs = append(errs, FieldError{Field: "difficulty", Message: "is required"})
+	case !catalogue.HasDifficulty(req.Difficulty):
+		errs = append(errs, FieldError{Field: "difficulty", Message: fmt.Sprintf("unknown difficulty %q", req.Difficulty)})
+	}
+
+	switch {
+	case len(topics) == 0:
+		errs = append(errs, FieldError{Field: "topics", Message: "at least one topic is required"})
+	case len(topics) > catalogue.Size():
+		errs = append(errs, FieldError{Field: "topics", Message: fmt.Sprintf("at most %d topics are allowed", catalogue.Size())})
+	default:
+		for i, t := range topics {
+			if !catalogue.HasTopic(t) {
+				errs = append(errs, FieldError{Field: fmt.Sprintf("topics[%d]", i), Message: fmt.Sprintf("unknown topic %q", t)})
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateUserID(field, userID string) Errors {
+	switch {
+	case userID == "":
+		return Errors{{Field: field, Message: "is required"}}
+	case len(userID) > MaxUserIDLength:
+		return Errors{{Field: field, Message: fmt.Sprintf("must be at most %d characters", MaxUserIDLength)}}
+	case strings.IndexFunc(userID, func(r rune) bool { return unicode.IsSpace(r) || !unicode.IsPrint(r) }) >= 0:
+		return Errors{{Field: field, Message: "must not contain whitespace or control characters"}}
+	}
+	return nil
+}
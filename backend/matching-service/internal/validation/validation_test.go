@@ -0,0 +1,123 @@
+package validation
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"matching-service/internal/models"
+	"matching-service/internal/repository"
+)
+
+func TestNormalizeMatchRequest(t *testing.T) {
+	catalogue := NewCatalogue(nil)
+	// Every group on the frontend's topic picker, flattened as the Home page sends them
+	allGroups := strings.Fields(`array string linked-list doubly-linked-list
+		tree trie binary-tree binary-search-tree binary-indexed-tree segment-tree breadth-first-search depth-first-search topological-sort graph
+		dynamic-programming divide-and-conquer greedy
+		sorting bucket-sort counting-sort merge-sort radix-sort topological-sort`)
+	tooMany := append(catalogue.Topics(), "cooking")
+
+	tests := []struct {
+		name       string
+		req        models.MatchRequest
+		want       models.MatchRequest
+		wantFields []string
+	}{
+		{
+			name: "normalises case, whitespace and duplicates",
+			req:  models.MatchRequest{UserID: "  u1 ", Difficulty: " Easy", Topics: []string{"Array", " hash table ", "array", ""}},
+			want: models.MatchRequest{UserID: "u1", Difficulty: "easy", Topics: []string{"array", "hash-table"}},
+		},
+		{
+			name:       "missing everything",
+			req:        models.MatchRequest{},
+			wantFields: []string{"userId", "difficulty", "topics"},
+		},
+		{
+			name:       "unknown difficulty and topic",
+			req:        models.MatchRequest{UserID: "u1", Difficulty: "insane", Topics: []string{"array", "cooking"}},
+			wantFields: []string{"difficulty", "topics[1]"},
+		},
+		{
+			name:       "blank topics only",
+			req:        models.MatchRequest{UserID: "u1", Difficulty: "easy", Topics: []string{" ", ""}},
+			wantFields: []string{"topics"},
+		},
+		{
+			name:       "user id with whitespace inside",
+			req:        models.MatchRequest{UserID: "u 1", Difficulty: "easy", Topics: []string{"array"}},
+			wantFields: []string{"userId"},
+		},
+		{
+			name: "every topic group selected",
+			req:  models.MatchRequest{UserID: "u1", Difficulty: "easy", Topics: allGroups},
+			want: models.MatchRequest{UserID: "u1", Difficulty: "easy", Topics: allGroups[:len(allGroups)-1]},
+		},
+		{
+			name:       "more topics than the catalogue",
+			req:        models.MatchRequest{UserID: "u1", Difficulty: "easy", Topics: tooMany},
+			wantFields: []string{"topics"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			err := NormalizeMatchRequest(&req, catalogue)
+			if len(tt.wantFields) == 0 {
+				if err != nil {
+					t.Fatalf("NormalizeMatchRequest returned error: %v", err)
+				}
+				if req.UserID != tt.want.UserID || req.Difficulty != tt.want.Difficulty || strings.Join(req.Topics, ",") != strings.Join(tt.want.Topics, ",") {
+					t.Fatalf("normalised = %+v, want %+v", req, tt.want)
+				}
+				return
+			}
+
+			var errs Errors
+			if !errors.As(err, &errs) {
+				t.Fatalf("error = %v, want validation Errors", err)
+			}
+			var fields []string
+			for _, fe := range errs {
+				fields = append(fields, fe.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(tt.wantFields, ",") {
+				t.Fatalf("fields = %v, want %v", fields, tt.wantFields)
+			}
+		})
+	}
+}
+
+type stubTopicSource struct {
+	tags []repository.TopicTag
+	err  error
+}
+
+func (s stubTopicSource) GetTopicTags(ctx context.Context) ([]repository.TopicTag, error) {
+	return s.tags, s.err
+}
+
+func TestCatalogueRefresh(t *testing.T) {
+	catalogue := NewCatalogue([]string{"Array", "Graph"})
+	if !catalogue.HasTopic("array") || catalogue.HasTopic("string") {
+		t.Fatalf("configured topics = %v, want [array graph]", catalogue.Topics())
+	}
+
+	ctx := context.Background()
+	if err := catalogue.Refresh(ctx, stubTopicSource{tags: []repository.TopicTag{{Name: "String", Slug: "string"}}}); err != nil {
+		t.Fatalf("Refresh returned error: %v", err)
+	}
+	if got := strings.Join(catalogue.Topics(), ","); got != "string" {
+		t.Fatalf("topics after refresh = %s, want string", got)
+	}
+
+	// Failed or empty refreshes keep the current topics
+	_ = catalogue.Refresh(ctx, stubTopicSource{err: errors.New("down")})
+	_ = catalogue.Refresh(ctx, stubTopicSource{})
+	if got := strings.Join(catalogue.Topics(), ","); got != "string" {
+		t.Fatalf("topics = %s, want string kept", got)
+	}
+}
@@ -3,6 +3,7 @@ import type {
   CancelMatchResponse,
   GetMatchDetailsResponse,
   GetMatchStatusResponse,
+  HeartbeatResponse,
   RequestMatchPayload,
   RequestMatchResponse,
 } from "../types/types";
@@ -22,11 +23,16 @@ const matchingServiceApiRequest = async <T>(
   data?: unknown,
   headers?: Record<string, string>,
 ): Promise<T> => {
+  const token = localStorage.getItem("authToken");
   const response: AxiosResponse<T> = await apiClient({
     method,
     url,
     data,
-    headers,
+    headers: {
+      // Logged-out callers send no header rather than "Bearer null"
+      ...(token ? { Authorization: `Bearer ${token}` } : {}),
+      ...headers,
+    },
   });
 
   return response.data;
@@ -70,6 +76,17 @@ export const getMatchStatus = async (
   return response;
 };
 
+// Keeps a waiting user in their queue; polling the status alone does not
+export const sendHeartbeat = async (
+  userId: string,
+): Promise<HeartbeatResponse> => {
+  const response = await matchingServiceApiRequest<HeartbeatResponse>(
+    `/match/heartbeat/${userId}`,
+    "POST",
+  );
+  return response;
+};
+
 export const getMatchDetails = async (
   matchId: string,
 ): Promise<GetMatchDetailsResponse> => {
@@ -4,6 +4,7 @@ import {
   cancelMatchByUser,
   getMatchStatus,
   requestMatch,
+  sendHeartbeat,
 } from "../api/MatchingService";
 import type {
   GetMatchStatusResponse,
@@ -45,6 +46,12 @@ export const useCheckStatus = ({
     queryFn: async () => {
       const response = await getMatchStatus(userId);
       isPollingRef.current = response.status !== 2;
+      if (response.status === 1) {
+        // Polling the status does not keep the user queued; only heartbeats do
+        await sendHeartbeat(userId).catch((error) =>
+          console.error("Error sending heartbeat:", error),
+        );
+      }
       return response;
     },
     refetchInterval: (query) => {
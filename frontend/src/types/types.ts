@@ -46,6 +46,10 @@ export interface CancelMatchResponse {
   status: string;
 }
 
+export interface HeartbeatResponse {
+  status: "alive";
+}
+
 export interface RequestMatchPayload {
   userId: string;
   topics: string[];